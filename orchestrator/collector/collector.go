@@ -0,0 +1,242 @@
+// Package collector samples resource usage for every running server on
+// an interval and keeps a bounded history per server, so dashboards can
+// graph CPU, memory and I/O rates without polling the runtime
+// themselves.
+//
+//	c := collector.New(provider, collector.Config{Interval: 5 * time.Second})
+//	go c.Run(ctx)
+//
+//	history := c.Query(serverID, time.Now().Add(-15*time.Minute))
+//	summary, _ := c.Summary(serverID, time.Now().Add(-time.Hour))
+//	live := c.Subscribe(ctx, serverID)
+package collector
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Sample is one stats snapshot plus per-second rates derived from the
+// previous sample of the same server. Rates are zero on a server's
+// first sample.
+type Sample struct {
+	Time time.Time `json:"time"`
+	orchestrator.ContainerStats
+
+	NetRxRate     float64 `json:"net_rx_rate"`     // bytes/s
+	NetTxRate     float64 `json:"net_tx_rate"`     // bytes/s
+	DiskReadRate  float64 `json:"disk_read_rate"`  // bytes/s
+	DiskWriteRate float64 `json:"disk_write_rate"` // bytes/s
+}
+
+// Config controls a Collector.
+type Config struct {
+	// Interval between samples. Default 10 seconds.
+	Interval time.Duration
+	// Retention is how many samples are kept per server. Default 360
+	// (an hour at the default interval).
+	Retention int
+}
+
+const (
+	defaultInterval  = 10 * time.Second
+	defaultRetention = 360
+
+	// staleAfter is how many consecutive passes a server may be missing
+	// from StatsAll before its history is dropped. Tolerates the odd
+	// failed stats call without losing graphs.
+	staleAfter = 3
+
+	subscriberBuffer = 64
+)
+
+// Collector samples a StatsSource and stores the results.
+type Collector struct {
+	src orchestrator.StatsSource
+	cfg Config
+	now func() time.Time
+
+	mu     sync.RWMutex
+	series map[string]*series
+	subs   map[*subscriber]struct{}
+}
+
+type series struct {
+	samples *ring
+	missed  int // consecutive passes without a sample
+}
+
+type subscriber struct {
+	id string // empty for every server
+	ch chan Sample
+}
+
+func New(src orchestrator.StatsSource, cfg Config) *Collector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	return &Collector{
+		src:    src,
+		cfg:    cfg,
+		now:    time.Now,
+		series: make(map[string]*series),
+		subs:   make(map[*subscriber]struct{}),
+	}
+}
+
+// Run samples every Interval until ctx is cancelled. Failed passes are
+// logged and retried on the next tick.
+func (c *Collector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Collect(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("collector: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Collect takes one sample of every running server.
+func (c *Collector) Collect(ctx context.Context) error {
+	stats, err := c.src.StatsAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]bool, len(stats))
+	for _, st := range stats {
+		seen[st.ContainerID] = true
+
+		s, ok := c.series[st.ContainerID]
+		if !ok {
+			s = &series{samples: newRing(c.cfg.Retention)}
+			c.series[st.ContainerID] = s
+		}
+		s.missed = 0
+
+		sample := Sample{Time: now, ContainerStats: st}
+		if prev, ok := s.samples.last(); ok {
+			applyRates(&sample, prev)
+		}
+		s.samples.push(sample)
+		c.publish(sample)
+	}
+
+	for id, s := range c.series {
+		if seen[id] {
+			continue
+		}
+		s.missed++
+		if s.missed >= staleAfter {
+			delete(c.series, id)
+		}
+	}
+	return nil
+}
+
+// applyRates fills in sample's rates from the previous sample. A counter
+// lower than before means the server restarted and its counters reset,
+// so the whole current value counts as new traffic.
+func applyRates(sample *Sample, prev Sample) {
+	dt := sample.Time.Sub(prev.Time).Seconds()
+	if dt <= 0 {
+		return
+	}
+	rate := func(cur, old int64) float64 {
+		if cur < old {
+			return float64(cur) / dt
+		}
+		return float64(cur-old) / dt
+	}
+	sample.NetRxRate = rate(sample.NetRxBytes, prev.NetRxBytes)
+	sample.NetTxRate = rate(sample.NetTxBytes, prev.NetTxBytes)
+	sample.DiskReadRate = rate(sample.DiskReadBytes, prev.DiskReadBytes)
+	sample.DiskWriteRate = rate(sample.DiskWriteBytes, prev.DiskWriteBytes)
+}
+
+// Query returns a server's samples taken at or after since, oldest
+// first. A zero since returns the whole history.
+func (c *Collector) Query(id string, since time.Time) []Sample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, ok := c.series[id]
+	if !ok {
+		return nil
+	}
+	return s.samples.since(since)
+}
+
+// Latest returns a server's most recent sample.
+func (c *Collector) Latest(id string) (Sample, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, ok := c.series[id]
+	if !ok {
+		return Sample{}, false
+	}
+	return s.samples.last()
+}
+
+// Servers returns the IDs of servers with history.
+func (c *Collector) Servers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, 0, len(c.series))
+	for id := range c.series {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Subscribe delivers each new sample for id (or for every server when id
+// is empty) until ctx is cancelled, then closes the channel. Samples are
+// dropped if the subscriber falls behind.
+func (c *Collector) Subscribe(ctx context.Context, id string) <-chan Sample {
+	sub := &subscriber{id: id, ch: make(chan Sample, subscriberBuffer)}
+
+	c.mu.Lock()
+	c.subs[sub] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		delete(c.subs, sub)
+		close(sub.ch)
+		c.mu.Unlock()
+	}()
+	return sub.ch
+}
+
+// publish fans a sample out to subscribers. Callers must hold c.mu.
+func (c *Collector) publish(sample Sample) {
+	for sub := range c.subs {
+		if sub.id != "" && sub.id != sample.ContainerID {
+			continue
+		}
+		select {
+		case sub.ch <- sample:
+		default:
+		}
+	}
+}
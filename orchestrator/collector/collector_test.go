@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+type fakeSource struct {
+	stats map[string]orchestrator.ContainerStats
+}
+
+func (f *fakeSource) Stats(ctx context.Context, id string) (*orchestrator.ContainerStats, error) {
+	st := f.stats[id]
+	return &st, nil
+}
+
+func (f *fakeSource) StatsAll(ctx context.Context) ([]orchestrator.ContainerStats, error) {
+	var out []orchestrator.ContainerStats
+	for _, st := range f.stats {
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func newTestCollector(src *fakeSource, cfg Config) (*Collector, *time.Time) {
+	c := New(src, cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCollectRates(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{stats: map[string]orchestrator.ContainerStats{
+		"a": {ContainerID: "a", NetRxBytes: 1000, DiskWriteBytes: 500},
+	}}
+	c, now := newTestCollector(src, Config{})
+
+	if err := c.Collect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := c.Latest("a")
+	if first.NetRxRate != 0 {
+		t.Errorf("first NetRxRate = %v, want 0", first.NetRxRate)
+	}
+
+	*now = now.Add(10 * time.Second)
+	src.stats["a"] = orchestrator.ContainerStats{ContainerID: "a", NetRxBytes: 6000, DiskWriteBytes: 1500}
+	c.Collect(ctx)
+	s, _ := c.Latest("a")
+	if s.NetRxRate != 500 || s.DiskWriteRate != 100 {
+		t.Errorf("rates = rx %v, write %v; want 500, 100", s.NetRxRate, s.DiskWriteRate)
+	}
+
+	// Counters reset after a restart
+	*now = now.Add(10 * time.Second)
+	src.stats["a"] = orchestrator.ContainerStats{ContainerID: "a", NetRxBytes: 2000}
+	c.Collect(ctx)
+	s, _ = c.Latest("a")
+	if s.NetRxRate != 200 {
+		t.Errorf("NetRxRate after reset = %v, want 200", s.NetRxRate)
+	}
+}
+
+func TestRetentionAndQuery(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{stats: map[string]orchestrator.ContainerStats{"a": {ContainerID: "a"}}}
+	c, now := newTestCollector(src, Config{Retention: 3})
+	start := *now
+
+	for range 5 {
+		c.Collect(ctx)
+		*now = now.Add(time.Second)
+	}
+
+	all := c.Query("a", time.Time{})
+	if len(all) != 3 {
+		t.Fatalf("len = %d, want 3", len(all))
+	}
+	if !all[0].Time.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("oldest = %v, want %v", all[0].Time, start.Add(2*time.Second))
+	}
+
+	recent := c.Query("a", start.Add(3*time.Second))
+	if len(recent) != 2 {
+		t.Errorf("Query since = %d samples, want 2", len(recent))
+	}
+	if c.Query("missing", time.Time{}) != nil {
+		t.Error("Query of unknown server should be nil")
+	}
+}
+
+func TestStaleSeriesDropped(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{stats: map[string]orchestrator.ContainerStats{"a": {ContainerID: "a"}}}
+	c, _ := newTestCollector(src, Config{})
+
+	c.Collect(ctx)
+	delete(src.stats, "a")
+
+	for i := range staleAfter {
+		if _, ok := c.Latest("a"); !ok {
+			t.Fatalf("series dropped after %d missed passes", i)
+		}
+		c.Collect(ctx)
+	}
+	if _, ok := c.Latest("a"); ok {
+		t.Error("series kept after server disappeared")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{stats: map[string]orchestrator.ContainerStats{}}
+	c, now := newTestCollector(src, Config{})
+
+	for i := 1; i <= 100; i++ {
+		src.stats["a"] = orchestrator.ContainerStats{ContainerID: "a", CPUPercent: float64(i)}
+		c.Collect(ctx)
+		*now = now.Add(time.Second)
+	}
+
+	sum, ok := c.Summary("a", time.Time{})
+	if !ok {
+		t.Fatal("Summary returned no data")
+	}
+	want := Percentiles{Min: 1, Max: 100, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99}
+	if sum.CPUPercent != want {
+		t.Errorf("CPUPercent = %+v, want %+v", sum.CPUPercent, want)
+	}
+	if sum.Samples != 100 {
+		t.Errorf("Samples = %d, want 100", sum.Samples)
+	}
+
+	if _, ok := c.Summary("missing", time.Time{}); ok {
+		t.Error("Summary of unknown server should report no data")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := &fakeSource{stats: map[string]orchestrator.ContainerStats{
+		"a": {ContainerID: "a"},
+		"b": {ContainerID: "b"},
+	}}
+	c, _ := newTestCollector(src, Config{})
+
+	onlyA := c.Subscribe(ctx, "a")
+	every := c.Subscribe(ctx, "")
+	c.Collect(ctx)
+
+	if s := <-onlyA; s.ContainerID != "a" {
+		t.Errorf("filtered subscriber got %s", s.ContainerID)
+	}
+	select {
+	case s := <-onlyA:
+		t.Errorf("filtered subscriber got extra sample for %s", s.ContainerID)
+	default:
+	}
+	if len(every) != 2 {
+		t.Errorf("unfiltered subscriber has %d samples, want 2", len(every))
+	}
+
+	cancel()
+	for range onlyA {
+	}
+}
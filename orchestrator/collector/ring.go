@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"sort"
+	"time"
+)
+
+// ring is a fixed-capacity buffer of samples in time order; pushing to
+// a full ring overwrites the oldest sample.
+type ring struct {
+	buf   []Sample
+	start int // index of the oldest sample
+	n     int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{buf: make([]Sample, capacity)}
+}
+
+func (r *ring) push(s Sample) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = s
+		r.n++
+		return
+	}
+	r.buf[r.start] = s
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *ring) at(i int) Sample {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *ring) last() (Sample, bool) {
+	if r.n == 0 {
+		return Sample{}, false
+	}
+	return r.at(r.n - 1), true
+}
+
+// since copies out the samples taken at or after t, oldest first.
+func (r *ring) since(t time.Time) []Sample {
+	first := sort.Search(r.n, func(i int) bool {
+		return !r.at(i).Time.Before(t)
+	})
+	out := make([]Sample, 0, r.n-first)
+	for i := first; i < r.n; i++ {
+		out = append(out, r.at(i))
+	}
+	return out
+}
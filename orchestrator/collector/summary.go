@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentiles summarises one metric over a window.
+type Percentiles struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+}
+
+// Summary describes a server's usage over a window of samples.
+type Summary struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Samples int       `json:"samples"`
+
+	CPUPercent    Percentiles `json:"cpu_percent"`
+	MemoryUsed    Percentiles `json:"memory_used"`
+	NetRxRate     Percentiles `json:"net_rx_rate"`
+	NetTxRate     Percentiles `json:"net_tx_rate"`
+	DiskReadRate  Percentiles `json:"disk_read_rate"`
+	DiskWriteRate Percentiles `json:"disk_write_rate"`
+}
+
+// Summary computes percentiles over a server's samples taken at or after
+// since. Returns false when there are no samples in the window.
+func (c *Collector) Summary(id string, since time.Time) (Summary, bool) {
+	samples := c.Query(id, since)
+	if len(samples) == 0 {
+		return Summary{}, false
+	}
+
+	metric := func(get func(Sample) float64) Percentiles {
+		values := make([]float64, len(samples))
+		for i, s := range samples {
+			values[i] = get(s)
+		}
+		return percentiles(values)
+	}
+
+	return Summary{
+		From:          samples[0].Time,
+		To:            samples[len(samples)-1].Time,
+		Samples:       len(samples),
+		CPUPercent:    metric(func(s Sample) float64 { return s.CPUPercent }),
+		MemoryUsed:    metric(func(s Sample) float64 { return float64(s.MemoryUsed) }),
+		NetRxRate:     metric(func(s Sample) float64 { return s.NetRxRate }),
+		NetTxRate:     metric(func(s Sample) float64 { return s.NetTxRate }),
+		DiskReadRate:  metric(func(s Sample) float64 { return s.DiskReadRate }),
+		DiskWriteRate: metric(func(s Sample) float64 { return s.DiskWriteRate }),
+	}, true
+}
+
+// percentiles uses the nearest-rank method, so every reported value is
+// one that was actually observed. values is sorted in place.
+func percentiles(values []float64) Percentiles {
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(values)))) - 1
+		return values[max(i, 0)]
+	}
+
+	return Percentiles{
+		Min:  values[0],
+		Max:  values[len(values)-1],
+		Mean: sum / float64(len(values)),
+		P50:  rank(50),
+		P90:  rank(90),
+		P95:  rank(95),
+		P99:  rank(99),
+	}
+}
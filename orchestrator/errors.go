@@ -0,0 +1,33 @@
+package orchestrator
+
+import "errors"
+
+// Errors shared by every provider. Providers wrap the underlying runtime
+// error with one of these, so callers can use errors.Is instead of
+// matching provider-specific messages:
+//
+//	if errors.Is(err, orchestrator.ErrNotFound) {
+//		// server is already gone
+//	}
+var (
+	// ErrNotFound means the server (or another named object such as a
+	// network or file) does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrAlreadyExists means a server with the requested name exists.
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrNotRunning means the operation needs a running server.
+	ErrNotRunning = errors.New("not running")
+
+	// ErrImageMissing means the image isn't available locally and
+	// couldn't be pulled (or the pull policy forbids pulling).
+	ErrImageMissing = errors.New("image missing")
+
+	// ErrResourceExhausted means the host ran out of something the
+	// request needs, such as free ports in a range.
+	ErrResourceExhausted = errors.New("resource exhausted")
+
+	// ErrUnavailable means the runtime couldn't be reached.
+	ErrUnavailable = errors.New("provider unavailable")
+)
@@ -0,0 +1,44 @@
+package orchestrator
+
+import "context"
+
+// Lifecycle actions reported in ContainerEvent.Action.
+const (
+	EventCreate       = "create"
+	EventStart        = "start"
+	EventStop         = "stop"
+	EventDie          = "die"
+	EventOOM          = "oom"
+	EventRestart      = "restart"
+	EventPause        = "pause"
+	EventUnpause      = "unpause"
+	EventHealthStatus = "health_status"
+	EventDestroy      = "destroy"
+)
+
+// ContainerEvent represents a server lifecycle event.
+type ContainerEvent struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Action      string `json:"action"` // one of the Event* constants
+	Time        int64  `json:"time"`
+	TimeNano    int64  `json:"time_nano,omitempty"`
+	// ExitCode is the main process's exit code. Set on die.
+	ExitCode int `json:"exit_code,omitempty"`
+	// OOMKilled reports that the kernel killed the server for exceeding
+	// its memory limit. Set on oom and the die that follows it.
+	OOMKilled bool `json:"oom_killed,omitempty"`
+	// Health is the new health status (HealthHealthy etc.). Set on
+	// health_status.
+	Health string `json:"health,omitempty"`
+}
+
+// EventSource is implemented by providers that can stream lifecycle
+// events. The event channel closes when the context is cancelled.
+// Providers that lose their connection to the runtime reconnect and
+// replay events from the last one delivered, reporting the disconnect
+// on the error channel without closing the stream; a provider that
+// cannot recover sends its error and closes both channels.
+type EventSource interface {
+	Events(ctx context.Context) (<-chan ContainerEvent, <-chan error)
+}
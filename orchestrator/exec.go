@@ -0,0 +1,30 @@
+package orchestrator
+
+import "time"
+
+// ExecOptions controls ExecWithOptions. The zero value runs the command
+// as the container's default user in its default working directory,
+// with no stdin and no time limit beyond the context.
+type ExecOptions struct {
+	Env        map[string]string
+	WorkingDir string
+	User       string
+	// Stdin is written to the command's standard input, which is then
+	// closed. Nil leaves stdin unattached.
+	Stdin []byte
+	// TTY allocates a terminal. Output then arrives as a single stream
+	// and is reported in Stdout.
+	TTY bool
+	// Timeout bounds how long to wait for the command. On expiry the
+	// output captured so far is returned along with the error.
+	Timeout time.Duration
+}
+
+// ExecResult is the outcome of a command run with ExecWithOptions. A
+// non-zero ExitCode is not an error: the command ran and failed.
+type ExecResult struct {
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Duration time.Duration `json:"duration"`
+}
@@ -0,0 +1,226 @@
+// Package hibernate pauses servers that have had no players for a while
+// and resumes them on demand, so idle lobbies stop burning CPU overnight
+// while staying in memory for an instant wake-up.
+//
+//	c := hibernate.New(provider, hibernate.RegistryPlayers(reg, nil), hibernate.Config{
+//		IdleAfter: 15 * time.Minute,
+//		Selector:  map[string]string{"type": "lobby"},
+//	})
+//	go c.Run(ctx)
+//
+//	// Before routing a player to a server:
+//	c.Wake(ctx, serverID)
+package hibernate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/registry"
+)
+
+// PlayerCounter reports how many players are on a server. ok is false
+// when the count is unknown (e.g. the server hasn't registered yet);
+// such servers are never paused.
+type PlayerCounter interface {
+	Players(ctx context.Context, server orchestrator.Server) (count int, ok bool, err error)
+}
+
+// PlayerCountFunc adapts a function to PlayerCounter.
+type PlayerCountFunc func(ctx context.Context, server orchestrator.Server) (int, bool, error)
+
+func (f PlayerCountFunc) Players(ctx context.Context, server orchestrator.Server) (int, bool, error) {
+	return f(ctx, server)
+}
+
+// RegistryPlayers counts players from a registry. key maps a server to
+// its registry ID; nil uses the server name without Docker's leading
+// slash. Game servers count the players in all their matches.
+func RegistryPlayers(reg *registry.Registry, key func(orchestrator.Server) string) PlayerCounter {
+	if key == nil {
+		key = func(s orchestrator.Server) string { return strings.TrimPrefix(s.Name, "/") }
+	}
+	return PlayerCountFunc(func(ctx context.Context, server orchestrator.Server) (int, bool, error) {
+		info, ok := reg.Get(key(server))
+		if !ok {
+			return 0, false, nil
+		}
+		count := info.Players
+		for _, match := range info.Matches {
+			count += len(match.Players)
+		}
+		return count, true, nil
+	})
+}
+
+// Config controls a Controller.
+type Config struct {
+	// IdleAfter is how long a server must have zero players before it
+	// is paused. Default 10 minutes.
+	IdleAfter time.Duration
+	// Interval between player count checks. Default 30 seconds.
+	Interval time.Duration
+	// Selector limits hibernation to servers whose labels match, using
+	// Provider.List semantics. Nil considers every server.
+	Selector map[string]string
+}
+
+const (
+	defaultIdleAfter = 10 * time.Minute
+	defaultInterval  = 30 * time.Second
+)
+
+// Controller tracks idle time per server and pauses idle ones.
+type Controller struct {
+	provider orchestrator.Provider
+	counter  PlayerCounter
+	cfg      Config
+	now      func() time.Time
+
+	mu        sync.Mutex
+	idleSince map[string]time.Time
+	pausing   map[string]chan struct{} // closed when an in-flight Pause returns
+}
+
+func New(provider orchestrator.Provider, counter PlayerCounter, cfg Config) *Controller {
+	if cfg.IdleAfter <= 0 {
+		cfg.IdleAfter = defaultIdleAfter
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	return &Controller{
+		provider:  provider,
+		counter:   counter,
+		cfg:       cfg,
+		now:       time.Now,
+		idleSince: make(map[string]time.Time),
+		pausing:   make(map[string]chan struct{}),
+	}
+}
+
+// Run checks servers every Interval until ctx is cancelled. Errors from
+// a pass are logged and the next pass retries.
+func (c *Controller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("hibernate: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Check runs one pass: it updates each running server's idle time and
+// pauses those idle for at least IdleAfter. Returns the IDs paused.
+func (c *Controller) Check(ctx context.Context) ([]string, error) {
+	servers, err := c.provider.List(ctx, c.cfg.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	seen := make(map[string]bool, len(servers))
+	var paused []string
+	var errs []error
+
+	for _, server := range servers {
+		seen[server.ID] = true
+		if server.Status != orchestrator.StatusRunning {
+			continue
+		}
+
+		count, ok, err := c.counter.Players(ctx, server)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if !ok || count > 0 {
+			delete(c.idleSince, server.ID)
+			c.mu.Unlock()
+			continue
+		}
+		since, tracked := c.idleSince[server.ID]
+		if !tracked {
+			c.idleSince[server.ID] = now
+			c.mu.Unlock()
+			continue
+		}
+		if now.Sub(since) < c.cfg.IdleAfter {
+			c.mu.Unlock()
+			continue
+		}
+		// Marked under the same lock as the idle check, so a Wake either
+		// resets the clock first or waits for this pause and undoes it
+		done := make(chan struct{})
+		c.pausing[server.ID] = done
+		c.mu.Unlock()
+
+		err = c.provider.Pause(ctx, server.ID)
+
+		c.mu.Lock()
+		delete(c.pausing, server.ID)
+		close(done)
+		if err == nil {
+			delete(c.idleSince, server.ID)
+		}
+		c.mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		paused = append(paused, server.ID)
+	}
+
+	// Forget servers that have been deallocated
+	c.mu.Lock()
+	for id := range c.idleSince {
+		if !seen[id] {
+			delete(c.idleSince, id)
+		}
+	}
+	c.mu.Unlock()
+
+	return paused, errors.Join(errs...)
+}
+
+// Wake resumes a server if it is paused and restarts its idle clock.
+// Call it before routing players to a server; servers that aren't
+// paused are left alone. A pause Check has already started is waited
+// for and then undone.
+func (c *Controller) Wake(ctx context.Context, id string) error {
+	c.mu.Lock()
+	delete(c.idleSince, id)
+	pausing := c.pausing[id]
+	c.mu.Unlock()
+
+	if pausing != nil {
+		select {
+		case <-pausing:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	server, err := c.provider.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if server.Status != orchestrator.StatusPaused {
+		return nil
+	}
+	return c.provider.Resume(ctx, id)
+}
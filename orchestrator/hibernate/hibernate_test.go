@@ -0,0 +1,166 @@
+package hibernate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+	"github.com/bananalabs-oss/potassium/registry"
+)
+
+func TestCheckPausesIdleServers(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	reg, _ := registry.New()
+
+	lobby, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby-1", Labels: map[string]string{"type": "lobby"}})
+	busy, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby-2", Labels: map[string]string{"type": "lobby"}})
+	game, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "game-1", Labels: map[string]string{"type": "game"}})
+	unregistered, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby-3", Labels: map[string]string{"type": "lobby"}})
+
+	reg.Register(registry.ServerInfo{ID: "lobby-1", Type: registry.TypeLobby})
+	reg.Register(registry.ServerInfo{ID: "lobby-2", Type: registry.TypeLobby, Players: 3})
+	reg.Register(registry.ServerInfo{ID: "game-1", Type: registry.TypeGame})
+
+	c := New(p, RegistryPlayers(reg, nil), Config{
+		IdleAfter: 10 * time.Minute,
+		Selector:  map[string]string{"type": "lobby"},
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// First sighting only starts the idle clock
+	if paused, err := c.Check(ctx); err != nil || len(paused) != 0 {
+		t.Fatalf("first Check = %v, %v", paused, err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	paused, err := c.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(paused) != 1 || paused[0] != lobby.ID {
+		t.Errorf("paused = %v, want [%s]", paused, lobby.ID)
+	}
+
+	for id, want := range map[string]orchestrator.ServerStatus{
+		lobby.ID:        orchestrator.StatusPaused,
+		busy.ID:         orchestrator.StatusRunning,
+		game.ID:         orchestrator.StatusRunning, // not selected
+		unregistered.ID: orchestrator.StatusRunning, // count unknown
+	} {
+		s, _ := p.Get(ctx, id)
+		if s.Status != want {
+			t.Errorf("%s status = %s, want %s", s.Name, s.Status, want)
+		}
+	}
+}
+
+func TestPlayersResetIdleClock(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	players := 0
+	counter := PlayerCountFunc(func(ctx context.Context, s orchestrator.Server) (int, bool, error) {
+		return players, true, nil
+	})
+	c := New(p, counter, Config{IdleAfter: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Check(ctx)
+	players = 1
+	now = now.Add(2 * time.Minute)
+	c.Check(ctx)
+	players = 0
+	now = now.Add(30 * time.Second)
+	if paused, _ := c.Check(ctx); len(paused) != 0 {
+		t.Errorf("server paused %v after only 30s idle", paused)
+	}
+	if p.Calls(orchestratortest.OpPause) != 0 {
+		t.Errorf("Pause called %d times", p.Calls(orchestratortest.OpPause))
+	}
+}
+
+func TestWake(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+	c := New(p, PlayerCountFunc(func(context.Context, orchestrator.Server) (int, bool, error) {
+		return 0, true, nil
+	}), Config{})
+
+	// Waking a running server is a no-op
+	if err := c.Wake(ctx, server.ID); err != nil {
+		t.Fatalf("Wake running: %v", err)
+	}
+	if n := p.Calls(orchestratortest.OpResume); n != 0 {
+		t.Errorf("Resume called %d times for a running server", n)
+	}
+
+	if err := p.Pause(ctx, server.ID); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := c.Wake(ctx, server.ID); err != nil {
+		t.Fatalf("Wake: %v", err)
+	}
+	s, _ := p.Get(ctx, server.ID)
+	if s.Status != orchestrator.StatusRunning {
+		t.Errorf("status = %s, want running", s.Status)
+	}
+}
+
+// slowPause holds Pause until release is closed.
+type slowPause struct {
+	*orchestratortest.Provider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p slowPause) Pause(ctx context.Context, id string) error {
+	close(p.started)
+	<-p.release
+	return p.Provider.Pause(ctx, id)
+}
+
+func TestWakeDuringPause(t *testing.T) {
+	ctx := context.Background()
+	p := slowPause{orchestratortest.New(), make(chan struct{}), make(chan struct{})}
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+	c := New(p, PlayerCountFunc(func(context.Context, orchestrator.Server) (int, bool, error) {
+		return 0, true, nil
+	}), Config{IdleAfter: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Check(ctx)
+	now = now.Add(2 * time.Minute)
+	checked := make(chan struct{})
+	go func() {
+		c.Check(ctx)
+		close(checked)
+	}()
+	<-p.started
+
+	// A player is routed to the server while the pause is in flight
+	woke := make(chan error)
+	go func() { woke <- c.Wake(ctx, server.ID) }()
+	select {
+	case err := <-woke:
+		t.Fatalf("Wake returned before the pause finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(p.release)
+	if err := <-woke; err != nil {
+		t.Fatalf("Wake: %v", err)
+	}
+	<-checked
+
+	s, _ := p.Get(ctx, server.ID)
+	if s.Status != orchestrator.StatusRunning {
+		t.Errorf("status = %s after Wake, want running", s.Status)
+	}
+}
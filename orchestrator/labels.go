@@ -0,0 +1,33 @@
+package orchestrator
+
+// LabelManaged is stamped on every server a provider allocates so List
+// only returns servers Potassium owns, never unrelated workloads on the
+// same host.
+const LabelManaged = "potassium.managed"
+
+// MatchLabels reports whether labels satisfy filter using the same
+// semantics as Provider.List: a non-empty filter value requires an exact
+// match, an empty value only requires the key to be present.
+func MatchLabels(labels, filter map[string]string) bool {
+	for key, want := range filter {
+		got, ok := labels[key]
+		if !ok {
+			return false
+		}
+		if want != "" && got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// ServerLabels returns the labels to stamp on a server allocated from
+// req: the caller's labels plus LabelManaged.
+func ServerLabels(req AllocateRequest) map[string]string {
+	labels := make(map[string]string, len(req.Labels)+1)
+	for key, value := range req.Labels {
+		labels[key] = value
+	}
+	labels[LabelManaged] = "true"
+	return labels
+}
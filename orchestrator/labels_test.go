@@ -0,0 +1,43 @@
+package orchestrator
+
+import "testing"
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{
+		"mode": "skywars",
+		"tier": "",
+	}
+
+	cases := []struct {
+		name   string
+		filter map[string]string
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"equality", map[string]string{"mode": "skywars"}, true},
+		{"mismatch", map[string]string{"mode": "survival"}, false},
+		{"existence", map[string]string{"tier": ""}, true},
+		{"missing key", map[string]string{"region": ""}, false},
+		{"all must match", map[string]string{"mode": "skywars", "region": "eu"}, false},
+	}
+	for _, c := range cases {
+		if got := MatchLabels(labels, c.filter); got != c.want {
+			t.Errorf("%s: MatchLabels = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestServerLabels_StampsManaged(t *testing.T) {
+	req := AllocateRequest{Labels: map[string]string{"mode": "skywars"}}
+
+	labels := ServerLabels(req)
+	if labels[LabelManaged] != "true" {
+		t.Errorf("%s = %q, want %q", LabelManaged, labels[LabelManaged], "true")
+	}
+	if labels["mode"] != "skywars" {
+		t.Errorf("mode = %q, want %q", labels["mode"], "skywars")
+	}
+	if _, ok := req.Labels[LabelManaged]; ok {
+		t.Error("ServerLabels must not mutate the request's labels")
+	}
+}
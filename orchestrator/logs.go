@@ -0,0 +1,32 @@
+package orchestrator
+
+import "time"
+
+// LogStream identifies which output stream a log line came from.
+type LogStream string
+
+const (
+	StreamStdout LogStream = "stdout"
+	StreamStderr LogStream = "stderr"
+)
+
+// LogLine is a single line of server output.
+type LogLine struct {
+	// Time is always set: the runtime's timestamp for the line when it
+	// records one, otherwise the time the line was read. There is no
+	// option to leave it out.
+	Time   time.Time `json:"time"`
+	Stream LogStream `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+// LogOptions controls StreamLogs. Zero Since/Until leave that end of the
+// window open; Tail limits how many lines of history are replayed (0
+// replays all of it). With Follow set the stream stays open and delivers
+// new output until the context is cancelled or the server exits.
+type LogOptions struct {
+	Since  time.Time `json:"since,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+	Tail   int       `json:"tail,omitempty"`
+	Follow bool      `json:"follow,omitempty"`
+}
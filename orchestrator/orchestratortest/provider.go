@@ -0,0 +1,759 @@
+// Package orchestratortest provides an in-memory orchestrator.Provider
+// so services built on the Provider interface can unit-test scheduling
+// logic without a Docker daemon.
+//
+// The fake keeps servers in a map, hands out IPs from a private subnet,
+// assigns host ports (honoring PortBinding.Range), records per-server
+// logs and emits the same lifecycle events the Docker provider does.
+// Failures and exec output are scripted per test:
+//
+//	p := orchestratortest.New()
+//	p.FailNext(orchestratortest.OpAllocate, errors.New("daemon down"))
+//	p.ScriptExec([]string{"list"}, "There are 0 players online", nil)
+package orchestratortest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Op names a Provider method for failure injection and call counting.
+type Op string
+
+const (
+	OpList       Op = "list"
+	OpGet        Op = "get"
+	OpAllocate   Op = "allocate"
+	OpDeallocate Op = "deallocate"
+	OpRestart    Op = "restart"
+	OpExec       Op = "exec"
+	OpLogs       Op = "logs"
+	OpStreamLogs Op = "stream_logs"
+	OpUpdate     Op = "update_resources"
+	OpPause      Op = "pause"
+	OpResume     Op = "resume"
+)
+
+// ExecFunc produces output for commands that have no scripted result.
+type ExecFunc func(server orchestrator.Server, cmd []string) (string, error)
+
+type execResult struct {
+	output string
+	err    error
+	result *orchestrator.ExecResult // set by ScriptExecResult
+}
+
+type fakeServer struct {
+	server orchestrator.Server
+	req    orchestrator.AllocateRequest
+	logs   []orchestrator.LogLine
+
+	// changed is closed and replaced whenever logs or status change so
+	// following log streams wake up.
+	changed chan struct{}
+}
+
+func (s *fakeServer) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Provider is an in-memory orchestrator.Provider. The zero value is not
+// usable; create one with New.
+type Provider struct {
+	mu sync.Mutex
+
+	servers map[string]*fakeServer
+	order   []string // creation order, so List is deterministic
+	nextID  int
+	nextIP  int
+
+	ports      *orchestrator.PortAllocator
+	nextPort   int
+	failNext   map[Op][]error
+	failAlways map[Op]error
+	calls      map[Op]int
+
+	stops       map[string]orchestrator.StopOptions
+	execs       map[string]orchestrator.ExecOptions
+	execScripts map[string]execResult
+	execHandler ExecFunc
+
+	subscribers map[chan orchestrator.ContainerEvent]struct{}
+}
+
+var _ orchestrator.Provider = (*Provider)(nil)
+var _ orchestrator.EventSource = (*Provider)(nil)
+
+// Subnet is the /24 prefix the first 253 fake server IPs are allocated
+// from. Later servers continue into 10.88.1.0/24 and up, so an address
+// is never handed out twice.
+const Subnet = "10.88.0."
+
+// firstEphemeralPort is where host ports start when a binding has
+// neither Host nor Range set, mirroring Docker's ephemeral range.
+const firstEphemeralPort = 32768
+
+func New() *Provider {
+	return &Provider{
+		servers:     make(map[string]*fakeServer),
+		ports:       orchestrator.NewPortAllocator(),
+		nextPort:    firstEphemeralPort,
+		failNext:    make(map[Op][]error),
+		failAlways:  make(map[Op]error),
+		calls:       make(map[Op]int),
+		stops:       make(map[string]orchestrator.StopOptions),
+		execs:       make(map[string]orchestrator.ExecOptions),
+		execScripts: make(map[string]execResult),
+		subscribers: make(map[chan orchestrator.ContainerEvent]struct{}),
+	}
+}
+
+// FailNext queues err to be returned by the next call to op. Queued
+// errors are consumed in order, one per call.
+func (p *Provider) FailNext(op Op, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext[op] = append(p.failNext[op], err)
+}
+
+// SetFailure makes every call to op return err until cleared with a nil
+// err.
+func (p *Provider) SetFailure(op Op, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.failAlways, op)
+		return
+	}
+	p.failAlways[op] = err
+}
+
+// Calls returns how many times op has been invoked, including calls
+// that failed.
+func (p *Provider) Calls(op Op) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[op]
+}
+
+// ScriptExec sets the result Exec returns for an exact command.
+func (p *Provider) ScriptExec(cmd []string, output string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.execScripts[strings.Join(cmd, "\x00")] = execResult{output: output, err: err}
+}
+
+// ScriptExecResult sets the full result ExecWithOptions returns for an
+// exact command, e.g. a non-zero exit code with stderr. Exec turns a
+// non-zero exit code into an error.
+func (p *Provider) ScriptExecResult(cmd []string, result orchestrator.ExecResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.execScripts[strings.Join(cmd, "\x00")] = execResult{result: &result}
+}
+
+// LastExecOptions returns the options passed to the most recent exec
+// in a server.
+func (p *Provider) LastExecOptions(id string) (orchestrator.ExecOptions, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	opts, ok := p.execs[id]
+	return opts, ok
+}
+
+// SetExecHandler installs a fallback for commands without a scripted
+// result. Without one, unscripted commands succeed with empty output.
+func (p *Provider) SetExecHandler(fn ExecFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.execHandler = fn
+}
+
+// AppendLogs adds stdout lines to a server's log buffer.
+func (p *Provider) AppendLogs(id string, lines ...string) error {
+	return p.appendLogs(id, orchestrator.StreamStdout, lines)
+}
+
+// AppendStderr adds stderr lines to a server's log buffer.
+func (p *Provider) AppendStderr(id string, lines ...string) error {
+	return p.appendLogs(id, orchestrator.StreamStderr, lines)
+}
+
+func (p *Provider) appendLogs(id string, stream orchestrator.LogStream, lines []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	now := time.Now()
+	for _, line := range lines {
+		s.logs = append(s.logs, orchestrator.LogLine{Time: now, Stream: stream, Text: line})
+	}
+	s.notify()
+	return nil
+}
+
+// Crash simulates the server process exiting on its own with exit code
+// 1: the server is marked stopped and a "die" event is emitted.
+func (p *Provider) Crash(id string) error {
+	return p.Exit(id, 1, false)
+}
+
+// Exit simulates the server process exiting with exitCode. With
+// oomKilled an "oom" event precedes the "die", as with Docker.
+func (p *Provider) Exit(id string, exitCode int, oomKilled bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	s.server.Status = orchestrator.StatusStopped
+	s.notify()
+	if oomKilled {
+		p.emitEvent(s.server, orchestrator.ContainerEvent{Action: orchestrator.EventOOM, OOMKilled: true})
+	}
+	p.emitEvent(s.server, orchestrator.ContainerEvent{
+		Action:    orchestrator.EventDie,
+		ExitCode:  exitCode,
+		OOMKilled: oomKilled,
+	})
+	return nil
+}
+
+// Request returns the AllocateRequest a server was created from.
+func (p *Provider) Request(id string) (orchestrator.AllocateRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return orchestrator.AllocateRequest{}, false
+	}
+	return s.req, true
+}
+
+// LastStopOptions returns the options passed to the most recent
+// Deallocate or Restart of a server, including deallocated ones.
+func (p *Provider) LastStopOptions(id string) (orchestrator.StopOptions, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	opts, ok := p.stops[id]
+	return opts, ok
+}
+
+// List returns servers matching filter (see orchestrator.Provider).
+func (p *Provider) List(ctx context.Context, filter map[string]string) ([]orchestrator.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpList); err != nil {
+		return nil, err
+	}
+
+	servers := []orchestrator.Server{}
+	for _, id := range p.order {
+		s := p.servers[id]
+		if orchestrator.MatchLabels(s.server.Labels, filter) {
+			servers = append(servers, copyServer(s.server))
+		}
+	}
+	return servers, nil
+}
+
+// Get returns a copy of the server.
+func (p *Provider) Get(ctx context.Context, id string) (*orchestrator.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpGet); err != nil {
+		return nil, err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	server := copyServer(s.server)
+	return &server, nil
+}
+
+// Allocate creates a running server with a fake IP and host ports.
+// Readiness specs are recorded but never waited on; call
+// orchestrator.WaitReady directly to exercise them.
+func (p *Provider) Allocate(ctx context.Context, req orchestrator.AllocateRequest) (*orchestrator.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpAllocate); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	p.nextID++
+	id := fmt.Sprintf("fake-%06d", p.nextID)
+	name := req.Name
+	if name == "" {
+		name = "server-" + strconv.Itoa(p.nextID)
+	}
+	for _, s := range p.servers {
+		if s.server.Name == name {
+			return nil, fmt.Errorf("server name %q: %w", name, orchestrator.ErrAlreadyExists)
+		}
+	}
+
+	ports, err := p.assignPorts(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := req.IP
+	if ip == "" {
+		p.nextIP++
+		ip = fakeIP(p.nextIP)
+	}
+
+	server := orchestrator.Server{
+		ID:          id,
+		Name:        name,
+		Status:      orchestrator.StatusRunning,
+		IP:          ip,
+		Ports:       ports,
+		CPULimit:    req.CPULimit,
+		MemoryLimit: req.MemoryLimit,
+		MemorySwap:  req.MemorySwap,
+		PidsLimit:   req.PidsLimit,
+		Labels:      orchestrator.ServerLabels(req),
+	}
+	p.servers[id] = &fakeServer{server: server, req: req, changed: make(chan struct{})}
+	p.order = append(p.order, id)
+
+	p.emit(server, "create")
+	p.emit(server, "start")
+	result := copyServer(server)
+	return &result, nil
+}
+
+// Deallocate removes the server and frees its ports.
+func (p *Provider) Deallocate(ctx context.Context, id string) error {
+	return p.DeallocateWithOptions(ctx, id, orchestrator.StopOptions{})
+}
+
+// DeallocateWithOptions removes the server, recording opts for
+// LastStopOptions.
+func (p *Provider) DeallocateWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpDeallocate); err != nil {
+		return err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+
+	for _, binding := range s.req.Ports {
+		if binding.Range != "" {
+			p.ports.Release(binding.Protocol, s.server.Ports[strconv.Itoa(binding.Container)])
+		}
+	}
+
+	p.stops[id] = opts
+	delete(p.servers, id)
+	s.notify()
+	for i, existing := range p.order {
+		if existing == id {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+
+	if alive(s.server.Status) {
+		p.emit(s.server, "die")
+		p.emit(s.server, "stop")
+	}
+	p.emit(s.server, "destroy")
+	return nil
+}
+
+// Restart marks the server running, whether it was running or stopped.
+func (p *Provider) Restart(ctx context.Context, id string) error {
+	return p.RestartWithOptions(ctx, id, orchestrator.StopOptions{})
+}
+
+// RestartWithOptions restarts the server, recording opts for
+// LastStopOptions.
+func (p *Provider) RestartWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpRestart); err != nil {
+		return err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+
+	if alive(s.server.Status) {
+		p.emit(s.server, "die")
+		p.emit(s.server, "stop")
+	}
+	p.stops[id] = opts
+	s.server.Status = orchestrator.StatusRunning
+	s.notify()
+	p.emit(s.server, "start")
+	p.emit(s.server, "restart")
+	return nil
+}
+
+// Pause moves a running server to StatusPaused.
+func (p *Provider) Pause(ctx context.Context, id string) error {
+	return p.setPaused(OpPause, id, true)
+}
+
+// Resume moves a paused server back to StatusRunning.
+func (p *Provider) Resume(ctx context.Context, id string) error {
+	return p.setPaused(OpResume, id, false)
+}
+
+func (p *Provider) setPaused(op Op, id string, pause bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(op); err != nil {
+		return err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+
+	from, to, action := orchestrator.StatusRunning, orchestrator.StatusPaused, "pause"
+	if !pause {
+		from, to, action = orchestrator.StatusPaused, orchestrator.StatusRunning, "unpause"
+	}
+	if s.server.Status != from {
+		return fmt.Errorf("server %s is %s: %w", id, s.server.Status, orchestrator.ErrNotRunning)
+	}
+	s.server.Status = to
+	s.notify()
+	p.emit(s.server, action)
+	return nil
+}
+
+// UpdateResources applies the non-zero limits in update to the server.
+func (p *Provider) UpdateResources(ctx context.Context, id string, update orchestrator.ResourceUpdate) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpUpdate); err != nil {
+		return err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if update.MemoryLimit > 0 {
+		s.server.MemoryLimit = update.MemoryLimit
+	}
+	if update.MemorySwap != 0 {
+		s.server.MemorySwap = update.MemorySwap
+	}
+	if update.CPULimit > 0 {
+		s.server.CPULimit = update.CPULimit
+	}
+	if update.PidsLimit > 0 {
+		s.server.PidsLimit = update.PidsLimit
+	}
+	return nil
+}
+
+// Exec returns the scripted output for cmd, falling back to the exec
+// handler. The server must be running.
+func (p *Provider) Exec(ctx context.Context, id string, cmd []string) (string, error) {
+	result, err := p.ExecWithOptions(ctx, id, cmd, orchestrator.ExecOptions{})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("exec exit code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+// ExecWithOptions answers from ScriptExec/ScriptExecResult or the exec
+// handler. Handler and ScriptExec output is reported as stdout with exit
+// code 0; their errors are returned as failures to run.
+func (p *Provider) ExecWithOptions(ctx context.Context, id string, cmd []string, opts orchestrator.ExecOptions) (*orchestrator.ExecResult, error) {
+	start := time.Now()
+	p.mu.Lock()
+
+	if err := p.begin(OpExec); err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if s.server.Status != orchestrator.StatusRunning {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotRunning)
+	}
+	p.execs[id] = opts
+
+	script, scripted := p.execScripts[strings.Join(cmd, "\x00")]
+	handler := p.execHandler
+	server := copyServer(s.server)
+	p.mu.Unlock()
+
+	switch {
+	case scripted && script.result != nil:
+		result := *script.result
+		return &result, nil
+	case scripted:
+		if script.err != nil {
+			return nil, script.err
+		}
+		return &orchestrator.ExecResult{Stdout: script.output, Duration: time.Since(start)}, nil
+	case handler == nil:
+		return &orchestrator.ExecResult{Duration: time.Since(start)}, nil
+	}
+
+	// Handler runs unlocked so it may call back into the provider
+	output, err := handler(server, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &orchestrator.ExecResult{Stdout: output, Duration: time.Since(start)}, nil
+}
+
+// Logs returns the last tail lines of the server's log buffer, or all
+// of them when tail <= 0.
+func (p *Provider) Logs(ctx context.Context, id string, tail int) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(OpLogs); err != nil {
+		return "", err
+	}
+
+	s, ok := p.servers[id]
+	if !ok {
+		return "", fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+
+	lines := s.logs
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// StreamLogs replays the server's log buffer and, when following, waits
+// for new lines until the context is cancelled or the server stops.
+func (p *Provider) StreamLogs(ctx context.Context, id string, opts orchestrator.LogOptions) (<-chan orchestrator.LogLine, <-chan error) {
+	lineCh := make(chan orchestrator.LogLine, 64)
+	errCh := make(chan error, 1)
+
+	p.mu.Lock()
+	err := p.begin(OpStreamLogs)
+	s, ok := p.servers[id]
+	if err == nil && !ok {
+		err = fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	var next int
+	if ok && opts.Tail > 0 && len(s.logs) > opts.Tail {
+		next = len(s.logs) - opts.Tail
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		errCh <- err
+		close(lineCh)
+		close(errCh)
+		return lineCh, errCh
+	}
+
+	go func() {
+		defer close(lineCh)
+		defer close(errCh)
+
+		for {
+			p.mu.Lock()
+			pending := append([]orchestrator.LogLine(nil), s.logs[next:]...)
+			next = len(s.logs)
+			changed := s.changed
+			_, exists := p.servers[id]
+			running := exists && alive(s.server.Status)
+			p.mu.Unlock()
+
+			for _, line := range pending {
+				if !opts.Since.IsZero() && line.Time.Before(opts.Since) {
+					continue
+				}
+				if !opts.Until.IsZero() && line.Time.After(opts.Until) {
+					return
+				}
+				select {
+				case lineCh <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if !opts.Follow || !running {
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return lineCh, errCh
+}
+
+// Events subscribes to lifecycle events. Each subscriber has a buffer
+// of 256 events; events are dropped for subscribers that fall further
+// behind so a stalled test can never deadlock the provider.
+func (p *Provider) Events(ctx context.Context) (<-chan orchestrator.ContainerEvent, <-chan error) {
+	eventCh := make(chan orchestrator.ContainerEvent, 256)
+	errCh := make(chan error, 1)
+
+	p.mu.Lock()
+	p.subscribers[eventCh] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		delete(p.subscribers, eventCh)
+		close(eventCh)
+		close(errCh)
+		p.mu.Unlock()
+	}()
+
+	return eventCh, errCh
+}
+
+// begin records a call to op and returns any injected failure. Callers
+// must hold p.mu.
+func (p *Provider) begin(op Op) error {
+	p.calls[op]++
+
+	if queued := p.failNext[op]; len(queued) > 0 {
+		p.failNext[op] = queued[1:]
+		return queued[0]
+	}
+	if err, ok := p.failAlways[op]; ok {
+		return err
+	}
+	return nil
+}
+
+// assignPorts resolves host ports for every binding. Callers must hold
+// p.mu.
+func (p *Provider) assignPorts(req orchestrator.AllocateRequest) (map[string]int, error) {
+	ports := map[string]int{}
+	var reserved []orchestrator.PortBinding
+
+	for _, binding := range req.Ports {
+		hostPort := binding.Host
+		switch {
+		case binding.Range != "":
+			low, high, err := orchestrator.ParsePortRange(binding.Range)
+			if err == nil {
+				hostPort, err = p.ports.Reserve(binding.Protocol, low, high, nil)
+			}
+			if err != nil {
+				for _, r := range reserved {
+					p.ports.Release(r.Protocol, r.Host)
+				}
+				return nil, err
+			}
+			reserved = append(reserved, orchestrator.PortBinding{Host: hostPort, Protocol: binding.Protocol})
+		case hostPort == 0:
+			hostPort = p.nextPort
+			p.nextPort++
+		}
+		ports[strconv.Itoa(binding.Container)] = hostPort
+	}
+	return ports, nil
+}
+
+// emit fans an event out to subscribers. Callers must hold p.mu.
+func (p *Provider) emit(server orchestrator.Server, action string) {
+	p.emitEvent(server, orchestrator.ContainerEvent{Action: action})
+}
+
+// emitEvent fills in the server and time on event and fans it out.
+// Callers must hold p.mu.
+func (p *Provider) emitEvent(server orchestrator.Server, event orchestrator.ContainerEvent) {
+	now := time.Now()
+	event.ContainerID = server.ID
+	event.Name = server.Name
+	event.Time = now.Unix()
+	event.TimeNano = now.UnixNano()
+	for ch := range p.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// alive reports whether a server has a process, possibly frozen.
+// fakeIP returns the nth fake address: Subnet.2 to Subnet.254, then
+// 10.88.1.1 onwards.
+func fakeIP(n int) string {
+	return fmt.Sprintf("10.88.%d.%d", n/254, n%254+1)
+}
+
+func alive(status orchestrator.ServerStatus) bool {
+	return status == orchestrator.StatusRunning || status == orchestrator.StatusPaused
+}
+
+func copyServer(s orchestrator.Server) orchestrator.Server {
+	ports := make(map[string]int, len(s.Ports))
+	for k, v := range s.Ports {
+		ports[k] = v
+	}
+	s.Ports = ports
+
+	labels := make(map[string]string, len(s.Labels))
+	for k, v := range s.Labels {
+		labels[k] = v
+	}
+	s.Labels = labels
+	return s
+}
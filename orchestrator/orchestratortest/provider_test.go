@@ -0,0 +1,352 @@
+package orchestratortest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func TestAllocateAssignsIPAndPorts(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Image: "test-server:latest",
+		Ports: []orchestrator.PortBinding{
+			{Container: 25565, Protocol: "tcp", Range: "30000-30001"},
+			{Host: 5521, Container: 5520, Protocol: "udp"},
+			{Container: 8080, Protocol: "tcp"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+
+	if server.Status != orchestrator.StatusRunning {
+		t.Errorf("Status = %q, want running", server.Status)
+	}
+	if server.IP == "" {
+		t.Error("IP should be assigned")
+	}
+	if server.Ports["25565"] != 30000 {
+		t.Errorf("range port = %d, want 30000", server.Ports["25565"])
+	}
+	if server.Ports["5520"] != 5521 {
+		t.Errorf("fixed port = %d, want 5521", server.Ports["5520"])
+	}
+	if server.Ports["8080"] != firstEphemeralPort {
+		t.Errorf("ephemeral port = %d, want %d", server.Ports["8080"], firstEphemeralPort)
+	}
+
+	second, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Ports: []orchestrator.PortBinding{{Container: 25565, Protocol: "tcp", Range: "30000-30001"}},
+	})
+	if err != nil {
+		t.Fatalf("second Allocate: %v", err)
+	}
+	if second.Ports["25565"] != 30001 {
+		t.Errorf("second range port = %d, want 30001", second.Ports["25565"])
+	}
+	if second.IP == server.IP {
+		t.Errorf("IPs should differ, both %s", server.IP)
+	}
+}
+
+func TestIPsNeverRepeat(t *testing.T) {
+	p := New()
+	seen := map[string]bool{}
+	for i := range 600 {
+		s, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && s.IP != Subnet+"2" {
+			t.Errorf("first IP = %s, want %s2", s.IP, Subnet)
+		}
+		if seen[s.IP] {
+			t.Fatalf("IP %s handed out twice (server %d)", s.IP, i)
+		}
+		seen[s.IP] = true
+	}
+}
+
+func TestListFiltersByLabel(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+
+	p.Allocate(ctx, orchestrator.AllocateRequest{Labels: map[string]string{"mode": "skywars"}})
+	p.Allocate(ctx, orchestrator.AllocateRequest{Labels: map[string]string{"mode": "survival"}})
+
+	all, _ := p.List(ctx, nil)
+	if len(all) != 2 {
+		t.Fatalf("List(nil) = %d servers, want 2", len(all))
+	}
+
+	skywars, _ := p.List(ctx, map[string]string{"mode": "skywars"})
+	if len(skywars) != 1 || skywars[0].Labels["mode"] != "skywars" {
+		t.Errorf("List(mode=skywars) = %+v", skywars)
+	}
+
+	managed, _ := p.List(ctx, map[string]string{orchestrator.LabelManaged: ""})
+	if len(managed) != 2 {
+		t.Errorf("every server should carry %s", orchestrator.LabelManaged)
+	}
+}
+
+func TestFailureInjection(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+	boom := errors.New("daemon down")
+
+	p.FailNext(OpAllocate, boom)
+	if _, err := p.Allocate(ctx, orchestrator.AllocateRequest{}); !errors.Is(err, boom) {
+		t.Fatalf("first Allocate err = %v, want %v", err, boom)
+	}
+	if _, err := p.Allocate(ctx, orchestrator.AllocateRequest{}); err != nil {
+		t.Fatalf("second Allocate should succeed: %v", err)
+	}
+
+	p.SetFailure(OpList, boom)
+	for i := 0; i < 2; i++ {
+		if _, err := p.List(ctx, nil); !errors.Is(err, boom) {
+			t.Fatalf("List err = %v, want %v", err, boom)
+		}
+	}
+	p.SetFailure(OpList, nil)
+	if _, err := p.List(ctx, nil); err != nil {
+		t.Fatalf("List after clear: %v", err)
+	}
+
+	if got := p.Calls(OpAllocate); got != 2 {
+		t.Errorf("Calls(OpAllocate) = %d, want 2", got)
+	}
+}
+
+func TestExecScriptsAndHandler(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	p.ScriptExec([]string{"list"}, "There are 0 players online", nil)
+	p.SetExecHandler(func(s orchestrator.Server, cmd []string) (string, error) {
+		return "handled " + cmd[0], nil
+	})
+
+	out, err := p.Exec(ctx, server.ID, []string{"list"})
+	if err != nil || out != "There are 0 players online" {
+		t.Errorf("scripted Exec = %q, %v", out, err)
+	}
+	out, err = p.Exec(ctx, server.ID, []string{"tps"})
+	if err != nil || out != "handled tps" {
+		t.Errorf("handler Exec = %q, %v", out, err)
+	}
+
+	p.Crash(server.ID)
+	if _, err := p.Exec(ctx, server.ID, []string{"list"}); err == nil {
+		t.Error("Exec on a stopped server should fail")
+	}
+}
+
+func TestExecWithOptions(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	p.ScriptExecResult([]string{"backup"}, orchestrator.ExecResult{
+		ExitCode: 2,
+		Stdout:   "saving chunks",
+		Stderr:   "disk full",
+	})
+
+	result, err := p.ExecWithOptions(ctx, server.ID, []string{"backup"}, orchestrator.ExecOptions{
+		Env:        map[string]string{"LEVEL": "world"},
+		WorkingDir: "/data",
+	})
+	if err != nil {
+		t.Fatalf("ExecWithOptions: %v", err)
+	}
+	if result.ExitCode != 2 || result.Stdout != "saving chunks" || result.Stderr != "disk full" {
+		t.Errorf("result = %+v", result)
+	}
+	if opts, _ := p.LastExecOptions(server.ID); opts.WorkingDir != "/data" || opts.Env["LEVEL"] != "world" {
+		t.Errorf("LastExecOptions = %+v", opts)
+	}
+
+	// Exec keeps its stdout-or-error contract
+	if _, err := p.Exec(ctx, server.ID, []string{"backup"}); err == nil {
+		t.Error("Exec should fail on a non-zero exit code")
+	}
+}
+
+func TestLogsTail(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	p.AppendLogs(server.ID, "one", "two", "three")
+
+	out, _ := p.Logs(ctx, server.ID, 2)
+	if out != "two\nthree\n" {
+		t.Errorf("Logs(tail=2) = %q", out)
+	}
+	out, _ = p.Logs(ctx, server.ID, 0)
+	if out != "one\ntwo\nthree\n" {
+		t.Errorf("Logs(tail=0) = %q", out)
+	}
+}
+
+func TestEventsLifecycle(t *testing.T) {
+	p := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, _ := p.Events(ctx)
+
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby-1"})
+	p.Crash(server.ID)
+	p.Restart(ctx, server.ID)
+	p.Deallocate(ctx, server.ID)
+
+	want := []string{"create", "start", "die", "start", "restart", "die", "stop", "destroy"}
+	for i, action := range want {
+		select {
+		case e := <-events:
+			if e.Action != action || e.ContainerID != server.ID || e.Name != "lobby-1" {
+				t.Fatalf("event %d = %+v, want action %q", i, e, action)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d (%s)", i, action)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestExitEvents(t *testing.T) {
+	p := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+	events, _ := p.Events(ctx)
+	p.Exit(server.ID, 137, true)
+
+	oom := <-events
+	die := <-events
+	if oom.Action != orchestrator.EventOOM || !oom.OOMKilled {
+		t.Errorf("first event = %+v, want oom", oom)
+	}
+	if die.Action != orchestrator.EventDie || die.ExitCode != 137 || !die.OOMKilled {
+		t.Errorf("second event = %+v, want die with exit 137 and OOMKilled", die)
+	}
+}
+
+func TestStreamLogsFollow(t *testing.T) {
+	p := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	p.AppendLogs(server.ID, "old-1", "old-2")
+
+	lines, errs := p.StreamLogs(ctx, server.ID, orchestrator.LogOptions{Tail: 1, Follow: true})
+
+	p.AppendStderr(server.ID, "boom")
+	p.AppendLogs(server.ID, "Server started")
+
+	want := []orchestrator.LogLine{
+		{Stream: orchestrator.StreamStdout, Text: "old-2"},
+		{Stream: orchestrator.StreamStderr, Text: "boom"},
+		{Stream: orchestrator.StreamStdout, Text: "Server started"},
+	}
+	for i, w := range want {
+		select {
+		case got := <-lines:
+			if got.Stream != w.Stream || got.Text != w.Text {
+				t.Fatalf("line %d = %+v, want %+v", i, got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for line %d", i)
+		}
+	}
+
+	// Stream ends when the server exits
+	p.Crash(server.ID)
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Fatal("expected stream to close after crash")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream did not close after crash")
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestErrorTaxonomy(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+
+	if _, err := p.Get(ctx, "missing"); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Errorf("Get missing: %v, want ErrNotFound", err)
+	}
+	if err := p.Deallocate(ctx, "missing"); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Errorf("Deallocate missing: %v, want ErrNotFound", err)
+	}
+
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Name:  "lobby",
+		Ports: []orchestrator.PortBinding{{Container: 25565, Protocol: "tcp", Range: "30000"}},
+	})
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	if _, err := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby"}); !errors.Is(err, orchestrator.ErrAlreadyExists) {
+		t.Errorf("duplicate name: %v, want ErrAlreadyExists", err)
+	}
+	_, err = p.Allocate(ctx, orchestrator.AllocateRequest{
+		Ports: []orchestrator.PortBinding{{Container: 25565, Protocol: "tcp", Range: "30000"}},
+	})
+	if !errors.Is(err, orchestrator.ErrResourceExhausted) {
+		t.Errorf("exhausted range: %v, want ErrResourceExhausted", err)
+	}
+
+	p.Crash(server.ID)
+	if _, err := p.Exec(ctx, server.ID, []string{"list"}); !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("Exec stopped: %v, want ErrNotRunning", err)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	p := New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	if err := p.Resume(ctx, server.ID); !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("Resume running server: %v, want ErrNotRunning", err)
+	}
+	if err := p.Pause(ctx, server.ID); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	got, _ := p.Get(ctx, server.ID)
+	if got.Status != orchestrator.StatusPaused {
+		t.Errorf("Status = %s, want paused", got.Status)
+	}
+	if _, err := p.Exec(ctx, server.ID, []string{"list"}); !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("Exec while paused: %v, want ErrNotRunning", err)
+	}
+	if err := p.Resume(ctx, server.ID); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	got, _ = p.Get(ctx, server.ID)
+	if got.Status != orchestrator.StatusRunning {
+		t.Errorf("Status = %s, want running", got.Status)
+	}
+}
@@ -0,0 +1,471 @@
+// Package pool keeps pre-allocated, ready servers per template so a
+// match can start on a warm server instead of waiting for a container
+// start and world load.
+//
+//	p, err := pool.New(provider, []pool.Template{
+//		{Name: "skywars", Size: 3, Request: skywarsReq},
+//		{Name: "bedwars", Size: 2, Request: bedwarsReq, ColdStart: true},
+//	}, pool.Config{})
+//	go p.Run(ctx)
+//
+//	server, err := p.Claim(ctx, "skywars") // server.Ports are ready to hand out
+//	// ... match ends
+//	err = p.Recycle(ctx, server.ID)
+//
+// The pool only tracks servers it created itself. Servers left over from
+// a previous process carry LabelPool but are never adopted or destroyed;
+// deallocating them before Run is up to the caller.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// LabelPool is set on pool servers to the name of their template.
+const LabelPool = "potassium.pool"
+
+// ErrEmpty is returned by Claim when a template has no idle server and
+// cold starts are off.
+var ErrEmpty = errors.New("no warm server available")
+
+// Template describes one kind of pooled server, usually a game mode.
+type Template struct {
+	Name string `json:"name" yaml:"name"`
+	// Size is how many idle, ready servers to keep.
+	Size int `json:"size" yaml:"size"`
+	// Request is allocated for each server. Name must be empty; the
+	// provider names pool servers. If Readiness is set, servers only
+	// become idle once it passes.
+	Request orchestrator.AllocateRequest `json:"request" yaml:"request"`
+	// ColdStart makes Claim allocate a server on the spot when the pool
+	// is empty instead of returning ErrEmpty.
+	ColdStart bool `json:"cold_start,omitempty" yaml:"cold_start,omitempty"`
+}
+
+// Config controls a Pool.
+type Config struct {
+	// Interval between checks that idle servers are still running, and
+	// between retries after a failed allocation. Default 15 seconds.
+	Interval time.Duration
+	// MaxConcurrentStarts caps allocations in flight across all
+	// templates. Default 4.
+	MaxConcurrentStarts int
+	// StopOptions are used when destroying servers.
+	StopOptions orchestrator.StopOptions
+}
+
+const (
+	defaultInterval            = 15 * time.Second
+	defaultMaxConcurrentStarts = 4
+)
+
+// Stats is the state of one template.
+type Stats struct {
+	Idle     int `json:"idle"`
+	Starting int `json:"starting"`
+	Claimed  int `json:"claimed"`
+	// LastError is the most recent allocation failure, cleared by the
+	// next success.
+	LastError string `json:"last_error,omitempty"`
+}
+
+type template struct {
+	Template
+	idle     []orchestrator.Server // oldest first
+	starting int
+	failedAt time.Time
+	lastErr  error
+}
+
+// Pool manages warm servers for a set of templates.
+type Pool struct {
+	provider orchestrator.Provider
+	cfg      Config
+	now      func() time.Time
+
+	mu        sync.Mutex
+	templates map[string]*template
+	order     []string
+	claimed   map[string]string // server ID → template
+	inFlight  int
+	closed    bool
+
+	nudge chan struct{}
+	wg    sync.WaitGroup // allocations in flight
+}
+
+func New(provider orchestrator.Provider, templates []Template, cfg Config) (*Pool, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.MaxConcurrentStarts <= 0 {
+		cfg.MaxConcurrentStarts = defaultMaxConcurrentStarts
+	}
+	p := &Pool{
+		provider:  provider,
+		cfg:       cfg,
+		now:       time.Now,
+		templates: make(map[string]*template, len(templates)),
+		claimed:   make(map[string]string),
+		nudge:     make(chan struct{}, 1),
+	}
+
+	var errs []error
+	for _, t := range templates {
+		switch {
+		case t.Name == "":
+			errs = append(errs, errors.New("template name required"))
+			continue
+		case p.templates[t.Name] != nil:
+			errs = append(errs, fmt.Errorf("template %s declared twice", t.Name))
+			continue
+		case t.Size < 0:
+			errs = append(errs, fmt.Errorf("template %s: size must not be negative", t.Name))
+		case t.Request.Name != "":
+			errs = append(errs, fmt.Errorf("template %s: request name must be empty", t.Name))
+		}
+		if err := t.Request.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", t.Name, err))
+		}
+		p.templates[t.Name] = &template{Template: t}
+		p.order = append(p.order, t.Name)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Run fills the pools and keeps them full until ctx is cancelled or
+// Close is called. Claims trigger a refill straight away; idle servers
+// that stop are dropped and replaced every Interval.
+func (p *Pool) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if p.isClosed() {
+			return nil
+		}
+		if err := p.prune(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("pool: %v", err)
+		}
+		p.fill(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-p.nudge:
+		case <-ctx.Done():
+			p.wg.Wait()
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) signal() {
+	select {
+	case p.nudge <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// fill starts allocations for every template below its size.
+func (p *Pool) fill(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for _, name := range p.order {
+		t := p.templates[name]
+		if !t.failedAt.IsZero() && now.Sub(t.failedAt) < p.cfg.Interval {
+			continue
+		}
+		for len(t.idle)+t.starting < t.Size && p.inFlight < p.cfg.MaxConcurrentStarts && !p.closed {
+			t.starting++
+			p.inFlight++
+			p.wg.Add(1)
+			go p.warm(ctx, t)
+		}
+	}
+}
+
+// warm allocates one server for t and adds it to the idle list once it
+// is ready.
+func (p *Pool) warm(ctx context.Context, t *template) {
+	defer p.wg.Done()
+	server, err := p.allocate(ctx, t.Template)
+
+	p.mu.Lock()
+	t.starting--
+	p.inFlight--
+	switch {
+	case err != nil:
+		t.failedAt = p.now()
+		t.lastErr = err
+	case p.closed:
+		// Closed while starting; destroyed below
+	default:
+		t.failedAt = time.Time{}
+		t.lastErr = nil
+		t.idle = append(t.idle, *server)
+	}
+	closed := p.closed
+	p.mu.Unlock()
+
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("pool: warming %s: %v", t.Name, err)
+		}
+		return
+	}
+	if closed {
+		p.destroy(context.WithoutCancel(ctx), server.ID)
+		return
+	}
+	// Another slot may have freed up while this one started
+	p.signal()
+}
+
+func (p *Pool) allocate(ctx context.Context, t Template) (*orchestrator.Server, error) {
+	req := t.Request
+	labels := make(map[string]string, len(req.Labels)+1)
+	for k, v := range req.Labels {
+		labels[k] = v
+	}
+	labels[LabelPool] = t.Name
+	req.Labels = labels
+
+	server, err := p.provider.Allocate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// Providers that honor Wait have already checked readiness
+	if req.Readiness != nil && !req.Readiness.Wait {
+		if err := orchestrator.WaitReady(ctx, p.provider, server.ID, *req.Readiness); err != nil {
+			p.destroy(context.WithoutCancel(ctx), server.ID)
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+// prune drops idle servers that are gone or no longer running. Only
+// servers already idle before List are judged: one that joins while
+// List runs may be missing from its result without being gone.
+func (p *Pool) prune(ctx context.Context) error {
+	p.mu.Lock()
+	judged := map[string]bool{}
+	for _, t := range p.templates {
+		for _, s := range t.idle {
+			judged[s.ID] = true
+		}
+	}
+	p.mu.Unlock()
+	if len(judged) == 0 {
+		return nil
+	}
+
+	servers, err := p.provider.List(ctx, map[string]string{LabelPool: ""})
+	if err != nil {
+		return err
+	}
+	running := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		running[s.ID] = s.Status == orchestrator.StatusRunning
+	}
+
+	var dead []string
+	p.mu.Lock()
+	for _, t := range p.templates {
+		kept := t.idle[:0]
+		for _, s := range t.idle {
+			alive, exists := running[s.ID]
+			switch {
+			case alive || !judged[s.ID]:
+				kept = append(kept, s)
+			case exists:
+				dead = append(dead, s.ID)
+			}
+		}
+		clear(t.idle[len(kept):])
+		t.idle = kept
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, id := range dead {
+		if err := p.destroy(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Claim hands out the longest-warmed idle server of a template; it
+// belongs to the caller until Recycle or Destroy. The pool is refilled
+// in the background.
+func (p *Pool) Claim(ctx context.Context, name string) (*orchestrator.Server, error) {
+	p.mu.Lock()
+	t, ok := p.templates[name]
+	if !ok {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("template %s: %w", name, orchestrator.ErrNotFound)
+	}
+	if len(t.idle) > 0 {
+		server := t.idle[0]
+		t.idle = t.idle[1:]
+		p.claimed[server.ID] = name
+		p.mu.Unlock()
+		p.signal()
+		return &server, nil
+	}
+	p.mu.Unlock()
+	p.signal()
+
+	if !t.ColdStart {
+		return nil, fmt.Errorf("template %s: %w", name, ErrEmpty)
+	}
+	server, err := p.allocate(ctx, t.Template)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.claimed[server.ID] = name
+	p.mu.Unlock()
+	return server, nil
+}
+
+// Recycle returns a claimed server to the pool: it is restarted and,
+// once ready again, becomes idle. If its template already has enough
+// servers it is destroyed instead.
+func (p *Pool) Recycle(ctx context.Context, id string) error {
+	p.mu.Lock()
+	name, ok := p.claimed[id]
+	if !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("server %s is not claimed from the pool: %w", id, orchestrator.ErrNotFound)
+	}
+	t := p.templates[name]
+	if p.closed || len(t.idle)+t.starting >= t.Size {
+		delete(p.claimed, id)
+		p.mu.Unlock()
+		return p.destroy(ctx, id)
+	}
+	// Counted as starting so fill doesn't allocate a replacement too
+	delete(p.claimed, id)
+	t.starting++
+	p.mu.Unlock()
+
+	server, err := p.reset(ctx, t.Template, id)
+
+	p.mu.Lock()
+	t.starting--
+	if err == nil && !p.closed {
+		t.idle = append(t.idle, *server)
+	}
+	closed := p.closed
+	p.mu.Unlock()
+
+	if err != nil {
+		p.destroy(context.WithoutCancel(ctx), id)
+		p.signal()
+		return fmt.Errorf("recycling %s: %w", id, err)
+	}
+	if closed {
+		return p.destroy(ctx, id)
+	}
+	return nil
+}
+
+// reset restarts a used server and waits for it to be ready.
+func (p *Pool) reset(ctx context.Context, t Template, id string) (*orchestrator.Server, error) {
+	if err := p.provider.RestartWithOptions(ctx, id, p.cfg.StopOptions); err != nil {
+		return nil, err
+	}
+	if spec := t.Request.Readiness; spec != nil {
+		if err := orchestrator.WaitReady(ctx, p.provider, id, *spec); err != nil {
+			return nil, err
+		}
+	}
+	return p.provider.Get(ctx, id)
+}
+
+// Destroy deallocates a claimed server; the pool replaces it if needed.
+func (p *Pool) Destroy(ctx context.Context, id string) error {
+	p.mu.Lock()
+	_, ok := p.claimed[id]
+	delete(p.claimed, id)
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("server %s is not claimed from the pool: %w", id, orchestrator.ErrNotFound)
+	}
+	return p.destroy(ctx, id)
+}
+
+func (p *Pool) destroy(ctx context.Context, id string) error {
+	err := p.provider.DeallocateWithOptions(ctx, id, p.cfg.StopOptions)
+	if errors.Is(err, orchestrator.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Stats reports each template's pool, keyed by template name.
+func (p *Pool) Stats() map[string]Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make(map[string]Stats, len(p.templates))
+	for name, t := range p.templates {
+		s := Stats{Idle: len(t.idle), Starting: t.starting}
+		if t.lastErr != nil {
+			s.LastError = t.lastErr.Error()
+		}
+		stats[name] = s
+	}
+	for _, name := range p.claimed {
+		s := stats[name]
+		s.Claimed++
+		stats[name] = s
+	}
+	return stats
+}
+
+// Close stops replenishing and destroys every idle server. Claimed
+// servers are left to their holders; Recycle destroys them from now on.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	var idle []string
+	for _, t := range p.templates {
+		for _, s := range t.idle {
+			idle = append(idle, s.ID)
+		}
+		t.idle = nil
+	}
+	p.mu.Unlock()
+	p.signal()
+
+	sort.Strings(idle)
+	var errs []error
+	for _, id := range idle {
+		if err := p.destroy(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
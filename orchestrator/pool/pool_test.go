@@ -0,0 +1,305 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+)
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var skywars = Template{
+	Name: "skywars",
+	Size: 2,
+	Request: orchestrator.AllocateRequest{
+		Image:  "hytale-skywars",
+		Ports:  []orchestrator.PortBinding{{Container: 5520, Protocol: "udp"}},
+		Labels: map[string]string{"mode": "skywars"},
+	},
+}
+
+func startPool(t *testing.T, provider orchestrator.Provider, templates []Template, cfg Config) *Pool {
+	t.Helper()
+	if cfg.Interval == 0 {
+		cfg.Interval = 20 * time.Millisecond
+	}
+	p, err := New(provider, templates, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return p
+}
+
+func idle(p *Pool, name string) func() bool {
+	return func() bool {
+		s := p.Stats()[name]
+		return s.Idle == p.templates[name].Size && s.Starting == 0
+	}
+}
+
+func TestClaimAndReplenish(t *testing.T) {
+	ctx := context.Background()
+	provider := orchestratortest.New()
+	p := startPool(t, provider, []Template{skywars}, Config{})
+	waitFor(t, "warm pool", idle(p, "skywars"))
+
+	server, err := p.Claim(ctx, "skywars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Ports["5520"] == 0 || server.Labels[LabelPool] != "skywars" || server.Labels["mode"] != "skywars" {
+		t.Errorf("claimed server = %+v", server)
+	}
+	waitFor(t, "replenish", idle(p, "skywars"))
+	if s := p.Stats()["skywars"]; s.Claimed != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// The claimed server is not handed out again
+	for range 2 {
+		other, err := p.Claim(ctx, "skywars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other.ID == server.ID {
+			t.Fatal("server claimed twice")
+		}
+	}
+
+	if _, err := p.Claim(ctx, "bedwars"); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Errorf("unknown template = %v", err)
+	}
+}
+
+func TestConcurrentClaims(t *testing.T) {
+	provider := orchestratortest.New()
+	big := skywars
+	big.Size = 10
+	p := startPool(t, provider, []Template{big}, Config{MaxConcurrentStarts: 10})
+	waitFor(t, "warm pool", idle(p, "skywars"))
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := p.Claim(context.Background(), "skywars")
+			if err != nil {
+				// Replenishment may lag behind ten simultaneous claims
+				if !errors.Is(err, ErrEmpty) {
+					t.Error(err)
+				}
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[s.ID] {
+				t.Errorf("%s claimed twice", s.ID)
+			}
+			seen[s.ID] = true
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEmptyAndColdStart(t *testing.T) {
+	ctx := context.Background()
+	provider := orchestratortest.New()
+	provider.SetFailure(orchestratortest.OpAllocate, orchestrator.ErrUnavailable)
+
+	cold := skywars
+	cold.Name = "cold"
+	cold.ColdStart = true
+	p := startPool(t, provider, []Template{skywars, cold}, Config{})
+
+	waitFor(t, "failed warm-up", func() bool { return p.Stats()["skywars"].LastError != "" })
+	if _, err := p.Claim(ctx, "skywars"); !errors.Is(err, ErrEmpty) {
+		t.Errorf("empty pool = %v", err)
+	}
+
+	provider.SetFailure(orchestratortest.OpAllocate, nil)
+	// Cold starts race the refill, so only check they succeed
+	if _, err := p.Claim(ctx, "cold"); err != nil {
+		t.Errorf("cold start = %v", err)
+	}
+	waitFor(t, "recovery", idle(p, "skywars"))
+	if s := p.Stats()["skywars"]; s.LastError != "" {
+		t.Errorf("error not cleared: %+v", s)
+	}
+}
+
+func TestRecycleAndDestroy(t *testing.T) {
+	ctx := context.Background()
+	provider := orchestratortest.New()
+	p := startPool(t, provider, []Template{skywars}, Config{})
+	waitFor(t, "warm pool", idle(p, "skywars"))
+
+	// Hold off the refill so the pool is short when the first returns
+	provider.SetFailure(orchestratortest.OpAllocate, orchestrator.ErrUnavailable)
+	first, _ := p.Claim(ctx, "skywars")
+	second, _ := p.Claim(ctx, "skywars")
+	waitFor(t, "failed refill", func() bool {
+		s := p.Stats()["skywars"]
+		return s.LastError != "" && s.Starting == 0
+	})
+	if err := p.Recycle(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats()["skywars"]; s.Idle != 1 || s.Claimed != 1 {
+		t.Errorf("after recycle = %+v", s)
+	}
+	if provider.Calls(orchestratortest.OpRestart) != 1 {
+		t.Error("recycled server was not restarted")
+	}
+	provider.SetFailure(orchestratortest.OpAllocate, nil)
+	waitFor(t, "refill", idle(p, "skywars"))
+
+	// Pool is full again, so the second one is destroyed
+	if err := p.Recycle(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Get(ctx, second.ID); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Error("surplus server kept")
+	}
+	if err := p.Recycle(ctx, second.ID); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Errorf("second Recycle = %v", err)
+	}
+
+	third, _ := p.Claim(ctx, "skywars")
+	if err := p.Destroy(ctx, third.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Get(ctx, third.ID); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Error("destroyed server still exists")
+	}
+}
+
+func TestPrunesDeadIdleServers(t *testing.T) {
+	ctx := context.Background()
+	provider := orchestratortest.New()
+	p := startPool(t, provider, []Template{skywars}, Config{})
+	waitFor(t, "warm pool", idle(p, "skywars"))
+
+	p.mu.Lock()
+	dead := p.templates["skywars"].idle[0].ID
+	p.mu.Unlock()
+	provider.Crash(dead)
+
+	waitFor(t, "dead server removed", func() bool {
+		_, err := provider.Get(ctx, dead)
+		return errors.Is(err, orchestrator.ErrNotFound)
+	})
+	waitFor(t, "replacement", idle(p, "skywars"))
+	for range 2 {
+		if s, _ := p.Claim(ctx, "skywars"); s.ID == dead {
+			t.Error("claimed a crashed server")
+		}
+	}
+}
+
+// staleList returns List results from before hook ran, like a List
+// racing with a server joining the pool.
+type staleList struct {
+	*orchestratortest.Provider
+	hook func()
+}
+
+func (s *staleList) List(ctx context.Context, filter map[string]string) ([]orchestrator.Server, error) {
+	servers, err := s.Provider.List(ctx, filter)
+	if s.hook != nil {
+		s.hook()
+	}
+	return servers, err
+}
+
+func TestPruneKeepsServersAddedDuringList(t *testing.T) {
+	ctx := context.Background()
+	provider := &staleList{Provider: orchestratortest.New()}
+	p, err := New(provider, []Template{skywars}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := p.allocate(ctx, skywars)
+	p.templates["skywars"].idle = []orchestrator.Server{*first}
+
+	var late *orchestrator.Server
+	provider.hook = func() {
+		late, _ = p.allocate(ctx, skywars)
+		p.mu.Lock()
+		p.templates["skywars"].idle = append(p.templates["skywars"].idle, *late)
+		p.mu.Unlock()
+	}
+	if err := p.prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats()["skywars"]; s.Idle != 2 {
+		t.Errorf("idle after prune = %d, want 2", s.Idle)
+	}
+	if _, err := provider.Get(ctx, late.ID); err != nil {
+		t.Errorf("late server: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	provider := orchestratortest.New()
+	p := startPool(t, provider, []Template{skywars}, Config{})
+	waitFor(t, "warm pool", idle(p, "skywars"))
+	claimed, _ := p.Claim(ctx, "skywars")
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	list, _ := provider.List(ctx, map[string]string{LabelPool: ""})
+	if len(list) != 1 || list[0].ID != claimed.ID {
+		t.Errorf("after Close = %+v, want only the claimed server", list)
+	}
+	if err := p.Recycle(ctx, claimed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := provider.List(ctx, nil); len(list) != 0 {
+		t.Errorf("%d servers left after recycling into a closed pool", len(list))
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	named := skywars
+	named.Request.Name = "fixed"
+	for _, templates := range [][]Template{
+		{skywars, skywars},
+		{named},
+		{{Size: 1}},
+	} {
+		if _, err := New(orchestratortest.New(), templates, Config{}); err == nil {
+			t.Errorf("accepted %+v", templates)
+		}
+	}
+}
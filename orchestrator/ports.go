@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParsePortRange parses a PortBinding.Range such as "25565-25599" into
+// its inclusive bounds. A single port ("25565") is a range of one.
+func ParsePortRange(s string) (int, int, error) {
+	lowStr, highStr, found := strings.Cut(strings.TrimSpace(s), "-")
+	if !found {
+		highStr = lowStr
+	}
+
+	low, err := strconv.Atoi(strings.TrimSpace(lowStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(highStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+
+	if low < 1 || high > 65535 || low > high {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+	return low, high, nil
+}
+
+// PortAllocator hands out host ports from a range. Reservations are held
+// in memory until Release, so concurrent allocations in the same process
+// never pick the same port even before the runtime has bound it.
+type PortAllocator struct {
+	mu       sync.Mutex
+	reserved map[string]struct{} // "udp/25565"
+}
+
+func NewPortAllocator() *PortAllocator {
+	return &PortAllocator{
+		reserved: make(map[string]struct{}),
+	}
+}
+
+// Reserve picks the lowest port in [low, high] that is neither reserved
+// nor reported as taken by inUse, and reserves it. inUse may be nil.
+func (a *PortAllocator) Reserve(protocol string, low, high int, inUse func(port int) bool) (int, error) {
+	protocol = normalizeProtocol(protocol)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for port := low; port <= high; port++ {
+		key := portKey(protocol, port)
+		if _, ok := a.reserved[key]; ok {
+			continue
+		}
+		if inUse != nil && inUse(port) {
+			continue
+		}
+		a.reserved[key] = struct{}{}
+		return port, nil
+	}
+
+	return 0, fmt.Errorf("%w: no free %s port in range %d-%d", ErrResourceExhausted, protocol, low, high)
+}
+
+// Release returns a reserved port to the pool. Releasing a port that
+// isn't reserved is a no-op.
+func (a *PortAllocator) Release(protocol string, port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.reserved, portKey(normalizeProtocol(protocol), port))
+}
+
+// PortFree reports whether port can currently be bound on the host for
+// the given protocol ("tcp" or "udp"; empty means tcp).
+func PortFree(protocol string, port int) bool {
+	addr := fmt.Sprintf(":%d", port)
+
+	if normalizeProtocol(protocol) == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+func normalizeProtocol(protocol string) string {
+	if protocol == "" {
+		return "tcp"
+	}
+	return strings.ToLower(protocol)
+}
+
+func portKey(protocol string, port int) string {
+	return protocol + "/" + strconv.Itoa(port)
+}
@@ -0,0 +1,101 @@
+package orchestrator
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	cases := []struct {
+		in        string
+		low, high int
+		wantErr   bool
+	}{
+		{"25565-25599", 25565, 25599, false},
+		{" 25565 - 25566 ", 25565, 25566, false},
+		{"5521", 5521, 5521, false},
+		{"25599-25565", 0, 0, true},
+		{"0-10", 0, 0, true},
+		{"1-70000", 0, 0, true},
+		{"abc-def", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, c := range cases {
+		low, high, err := ParsePortRange(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParsePortRange(%q) err = %v, wantErr %v", c.in, err, c.wantErr)
+			continue
+		}
+		if low != c.low || high != c.high {
+			t.Errorf("ParsePortRange(%q) = %d-%d, want %d-%d", c.in, low, high, c.low, c.high)
+		}
+	}
+}
+
+func TestPortAllocator_SkipsInUseAndReserved(t *testing.T) {
+	a := NewPortAllocator()
+	inUse := func(port int) bool { return port == 30000 }
+
+	first, err := a.Reserve("udp", 30000, 30002, inUse)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if first != 30001 {
+		t.Errorf("first = %d, want 30001", first)
+	}
+
+	second, err := a.Reserve("udp", 30000, 30002, inUse)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if second != 30002 {
+		t.Errorf("second = %d, want 30002", second)
+	}
+
+	if _, err := a.Reserve("udp", 30000, 30002, inUse); err == nil {
+		t.Error("expected exhaustion error")
+	}
+
+	// Same port number on another protocol is independent
+	if _, err := a.Reserve("tcp", 30001, 30001, nil); err != nil {
+		t.Errorf("tcp reserve should not collide with udp: %v", err)
+	}
+
+	a.Release("udp", 30001)
+	again, err := a.Reserve("udp", 30000, 30002, inUse)
+	if err != nil || again != 30001 {
+		t.Errorf("after release got %d, %v; want 30001", again, err)
+	}
+}
+
+func TestPortAllocator_ConcurrentReservationsAreUnique(t *testing.T) {
+	a := NewPortAllocator()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		ports = map[int]bool{}
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := a.Reserve("tcp", 40000, 40049, nil)
+			if err != nil {
+				t.Errorf("Reserve: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ports[port] {
+				t.Errorf("port %d handed out twice", port)
+			}
+			ports[port] = true
+		}()
+	}
+	wg.Wait()
+
+	if len(ports) != 50 {
+		t.Errorf("got %d unique ports, want 50", len(ports))
+	}
+}
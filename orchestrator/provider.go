@@ -8,16 +8,20 @@ const (
 	StatusRunning ServerStatus = "running"
 	StatusStopped ServerStatus = "stopped"
 	StatusError   ServerStatus = "error"
+	StatusPaused  ServerStatus = "paused" // frozen in memory; see Provider.Pause
 )
 
 type Server struct {
-	ID          string         `json:"id"`
-	Name        string         `json:"name"`
-	Status      ServerStatus   `json:"status"`
-	IP          string         `json:"ip"`
-	Ports       map[string]int `json:"ports"`
-	CPULimit    float64        `json:"cpu_limit,omitempty"`
-	MemoryLimit int64          `json:"memory_limit,omitempty"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Status      ServerStatus      `json:"status"`
+	IP          string            `json:"ip"`
+	Ports       map[string]int    `json:"ports"`
+	CPULimit    float64           `json:"cpu_limit,omitempty"`
+	MemoryLimit int64             `json:"memory_limit,omitempty"`
+	MemorySwap  int64             `json:"memory_swap,omitempty"`
+	PidsLimit   int64             `json:"pids_limit,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
 }
 
 type PortBinding struct {
@@ -28,29 +32,108 @@ type PortBinding struct {
 	Range     string `json:"range,omitempty" yaml:"range,omitempty"` // e.g. "25565-25599" — allocate host port from this range
 }
 
+// PullPolicy controls whether Allocate pulls the image first.
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present" // default
+	PullNever        PullPolicy = "never"
+)
+
 type AllocateRequest struct {
-	Image       string            `json:"image" yaml:"image"`
-	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`
-	Environment map[string]string `json:"environment" yaml:"environment"`
-	Volumes     map[string]string `json:"volumes" yaml:"volumes"`
-	Ports       []PortBinding     `json:"ports" yaml:"ports"`
-	Network     string            `json:"network,omitempty" yaml:"network,omitempty"`
-	IP          string            `json:"ip,omitempty" yaml:"ip,omitempty"`
-	MemoryLimit    int64   `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
-	CPULimit       float64 `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
-	DiskIOReadBps  int64   `json:"disk_io_read_bps,omitempty" yaml:"disk_io_read_bps,omitempty"`
-	DiskIOWriteBps int64   `json:"disk_io_write_bps,omitempty" yaml:"disk_io_write_bps,omitempty"`
-	DiskSizeLimit  int64   `json:"disk_size_limit,omitempty" yaml:"disk_size_limit,omitempty"`
-	PidsLimit      int64   `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
-	MemorySwap     int64   `json:"memory_swap,omitempty" yaml:"memory_swap,omitempty"`
+	Image          string            `json:"image" yaml:"image"`
+	PullPolicy     PullPolicy        `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
+	Name           string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Environment    map[string]string `json:"environment" yaml:"environment"`
+	Volumes        map[string]string `json:"volumes" yaml:"volumes"`
+	Ports          []PortBinding     `json:"ports" yaml:"ports"`
+	Network        string            `json:"network,omitempty" yaml:"network,omitempty"`
+	IP             string            `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	MemoryLimit    int64             `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	CPULimit       float64           `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
+	DiskIOReadBps  int64             `json:"disk_io_read_bps,omitempty" yaml:"disk_io_read_bps,omitempty"`
+	DiskIOWriteBps int64             `json:"disk_io_write_bps,omitempty" yaml:"disk_io_write_bps,omitempty"`
+	DiskSizeLimit  int64             `json:"disk_size_limit,omitempty" yaml:"disk_size_limit,omitempty"`
+	PidsLimit      int64             `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
+	MemorySwap     int64             `json:"memory_swap,omitempty" yaml:"memory_swap,omitempty"`
+	Readiness      *ReadinessSpec    `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+
+	// Process
+	Command    []string `json:"command,omitempty" yaml:"command,omitempty"`
+	Entrypoint []string `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	WorkingDir string   `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	User       string   `json:"user,omitempty" yaml:"user,omitempty"` // uid, name, or user:group
+
+	// Lifecycle
+	RestartPolicy     string `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"` // no, always, unless-stopped, on-failure
+	RestartMaxRetries int    `json:"restart_max_retries,omitempty" yaml:"restart_max_retries,omitempty"`
+
+	// Kernel and filesystem
+	Ulimits        []Ulimit          `json:"ulimits,omitempty" yaml:"ulimits,omitempty"`
+	Sysctls        map[string]string `json:"sysctls,omitempty" yaml:"sysctls,omitempty"`
+	Tmpfs          map[string]string `json:"tmpfs,omitempty" yaml:"tmpfs,omitempty"` // container path → mount options, e.g. "size=64m"
+	ReadOnlyRootfs bool              `json:"read_only_rootfs,omitempty" yaml:"read_only_rootfs,omitempty"`
+
+	// Security
+	CapAdd      []string `json:"cap_add,omitempty" yaml:"cap_add,omitempty"`
+	CapDrop     []string `json:"cap_drop,omitempty" yaml:"cap_drop,omitempty"`
+	SecurityOpt []string `json:"security_opt,omitempty" yaml:"security_opt,omitempty"` // e.g. "no-new-privileges"
+
+	// Name resolution
+	DNS        []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	ExtraHosts []string `json:"extra_hosts,omitempty" yaml:"extra_hosts,omitempty"` // "host:ip"
+}
+
+// Ulimit is a resource limit (see setrlimit(2)) such as "nofile". A
+// negative value means unlimited.
+type Ulimit struct {
+	Name string `json:"name" yaml:"name"`
+	Soft int64  `json:"soft" yaml:"soft"`
+	Hard int64  `json:"hard" yaml:"hard"`
+}
+
+// ResourceUpdate changes the limits of an existing server in place.
+// Zero fields are left unchanged. Disk I/O throttles and disk size can
+// only be set at allocation time.
+type ResourceUpdate struct {
+	MemoryLimit int64   `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	MemorySwap  int64   `json:"memory_swap,omitempty" yaml:"memory_swap,omitempty"`
+	CPULimit    float64 `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
+	PidsLimit   int64   `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
 }
 
 type Provider interface {
+	// List returns servers created by this provider. filter matches on
+	// labels: a non-empty value requires equality, an empty value only
+	// requires the label to be present. A nil filter returns everything.
 	List(ctx context.Context, filter map[string]string) ([]Server, error)
 	Get(ctx context.Context, id string) (*Server, error)
 	Allocate(ctx context.Context, req AllocateRequest) (*Server, error)
 	Deallocate(ctx context.Context, id string) error
+	DeallocateWithOptions(ctx context.Context, id string, opts StopOptions) error
 	Restart(ctx context.Context, id string) error
+	RestartWithOptions(ctx context.Context, id string, opts StopOptions) error
+	// Pause freezes every process in a running server. Memory stays
+	// allocated but no CPU is used until Resume; connected clients time
+	// out if it lasts longer than their keep-alive.
+	Pause(ctx context.Context, id string) error
+	Resume(ctx context.Context, id string) error
+	// UpdateResources applies new limits to a server without recreating
+	// it. Get reflects the effective limits afterwards.
+	UpdateResources(ctx context.Context, id string, update ResourceUpdate) error
+	// Exec runs cmd in the server and returns its stdout. A non-zero exit
+	// code is returned as an error carrying stderr.
 	Exec(ctx context.Context, id string, cmd []string) (string, error)
+	// ExecWithOptions runs cmd and reports its exit code and both output
+	// streams. The error is only set when the command couldn't be run or
+	// waited on.
+	ExecWithOptions(ctx context.Context, id string, cmd []string, opts ExecOptions) (*ExecResult, error)
 	Logs(ctx context.Context, id string, tail int) (string, error)
+	// StreamLogs delivers log lines on the returned channel, which closes
+	// when the history is exhausted (or, when following, the context is
+	// cancelled or the server exits). A failure is sent on the error
+	// channel before both close.
+	StreamLogs(ctx context.Context, id string, opts LogOptions) (<-chan LogLine, <-chan error)
 }
@@ -0,0 +1,345 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ArchiveFormat selects the archive encoding for Upload and Download.
+type ArchiveFormat string
+
+const (
+	ArchiveTar ArchiveFormat = "tar"
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// DefaultMaxTransferBytes caps Upload and Download when
+// TransferOptions.MaxBytes is unset.
+const DefaultMaxTransferBytes = 1 << 30 // 1 GiB
+
+// ErrTransferTooLarge is returned when an archive's contents exceed the
+// transfer size limit.
+var ErrTransferTooLarge = errors.New("archive exceeds size limit")
+
+// TransferOptions controls Upload and Download.
+type TransferOptions struct {
+	Format ArchiveFormat // tar (default) or zip
+	// MaxBytes limits the total uncompressed size of the files
+	// transferred. 0 uses DefaultMaxTransferBytes.
+	MaxBytes int64
+}
+
+func (o TransferOptions) limit() int64 {
+	if o.MaxBytes > 0 {
+		return o.MaxBytes
+	}
+	return DefaultMaxTransferBytes
+}
+
+// byteBudget tracks how much file content an archive may still carry.
+type byteBudget struct {
+	remaining int64
+}
+
+func (b *byteBudget) take(n int64) error {
+	if n > b.remaining {
+		return ErrTransferTooLarge
+	}
+	b.remaining -= n
+	return nil
+}
+
+// copyBudgeted copies one archive entry's content. Headers can lie about
+// sizes (zip in particular), so the budget is enforced on the bytes
+// actually read rather than the declared size.
+func copyBudgeted(dst io.Writer, src io.Reader, budget *byteBudget) error {
+	n, err := io.Copy(dst, io.LimitReader(src, budget.remaining+1))
+	if err != nil {
+		return err
+	}
+	return budget.take(n)
+}
+
+// safeEntryName cleans an archive entry name and rejects names that
+// would land outside the extraction directory. Backslashes are treated
+// as separators since zip files made on Windows use them.
+func safeEntryName(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("archive entry %q: absolute path", name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry %q: escapes destination", name)
+	}
+	return clean, nil
+}
+
+// symlinks records the symlinks an archive has created so far. Names
+// and targets are only checked as text, so without it a chain such as
+// l -> . followed by l/m -> .. and l/m/etc/x would write outside the
+// extraction directory.
+type symlinks map[string]bool
+
+// through rejects an entry name whose parent directories include a
+// symlink created earlier in the archive.
+func (s symlinks) through(entry, name string) error {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if s[dir] {
+			return fmt.Errorf("archive entry %q: path passes through symlink %q", entry, dir)
+		}
+	}
+	return nil
+}
+
+// safeLinkTarget rejects symlinks that point outside the extraction
+// directory or through another symlink. The target is resolved one
+// component at a time from the link's own directory, as the kernel
+// would, so a ".." after a symlink can't be cleaned away.
+func (s symlinks) safeLinkTarget(entry, name, target string) error {
+	if strings.HasPrefix(target, "/") {
+		return fmt.Errorf("archive entry %q: absolute symlink target %q", entry, target)
+	}
+	cur := path.Dir(name)
+	for _, part := range strings.Split(target, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		if s[cur] {
+			return fmt.Errorf("archive entry %q: symlink target %q passes through symlink %q", entry, target, cur)
+		}
+		if part != ".." {
+			cur = path.Join(cur, part)
+			continue
+		}
+		if cur == "." {
+			return fmt.Errorf("archive entry %q: symlink target %q escapes destination", entry, target)
+		}
+		cur = path.Dir(cur)
+	}
+	return nil
+}
+
+// sanitizeTar rewrites an uploaded tar stream entry by entry, dropping
+// nothing silently: entries that could escape the destination, special
+// files and content beyond the size limit all fail the upload.
+func sanitizeTar(dst io.Writer, src io.Reader, maxBytes int64) error {
+	budget := &byteBudget{remaining: maxBytes}
+	links := symlinks{}
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+
+		name, err := safeEntryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+		if err := links.through(hdr.Name, name); err != nil {
+			return err
+		}
+
+		out := &tar.Header{
+			Name:    name,
+			Mode:    hdr.Mode & 0o777, // no setuid, setgid or sticky bits
+			ModTime: hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			out.Typeflag = tar.TypeDir
+			out.Name += "/"
+		case tar.TypeReg:
+			out.Typeflag = tar.TypeReg
+			out.Size = hdr.Size
+		case tar.TypeSymlink:
+			if err := links.safeLinkTarget(hdr.Name, name, hdr.Linkname); err != nil {
+				return err
+			}
+			links[name] = true
+			out.Typeflag = tar.TypeSymlink
+			out.Linkname = hdr.Linkname
+		case tar.TypeLink:
+			target, err := safeEntryName(hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := links.through(hdr.Name, target); err != nil {
+				return err
+			}
+			// A hard link to a symlink is another symlink
+			links[name] = links[target]
+			out.Typeflag = tar.TypeLink
+			out.Linkname = target
+		default:
+			return fmt.Errorf("archive entry %q: unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+
+		if err := tw.WriteHeader(out); err != nil {
+			return fmt.Errorf("write tar: %w", err)
+		}
+		if out.Typeflag == tar.TypeReg {
+			if err := copyBudgeted(tw, tr, budget); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// zipToTar converts an uploaded zip into a tar stream with the same
+// checks as sanitizeTar. Only files and directories are accepted.
+func zipToTar(dst io.Writer, zr *zip.Reader, maxBytes int64) error {
+	budget := &byteBudget{remaining: maxBytes}
+	tw := tar.NewWriter(dst)
+
+	for _, f := range zr.File {
+		name, err := safeEntryName(f.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     int64(mode.Perm()) | 0o700,
+				ModTime:  f.Modified,
+			}); err != nil {
+				return fmt.Errorf("write tar: %w", err)
+			}
+		case mode.IsRegular():
+			if f.UncompressedSize64 > uint64(budget.remaining) {
+				return ErrTransferTooLarge
+			}
+			perm := mode.Perm()
+			if perm == 0 {
+				perm = 0o644
+			}
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     name,
+				Mode:     int64(perm),
+				Size:     int64(f.UncompressedSize64),
+				ModTime:  f.Modified,
+			}); err != nil {
+				return fmt.Errorf("write tar: %w", err)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("open zip entry %q: %w", f.Name, err)
+			}
+			err = copyBudgeted(tw, rc, budget)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive entry %q: unsupported type %s", f.Name, mode.Type())
+		}
+	}
+	return tw.Close()
+}
+
+// limitTar passes a downloaded tar stream through unchanged, failing
+// once its file content exceeds maxBytes.
+func limitTar(dst io.Writer, src io.Reader, maxBytes int64) error {
+	budget := &byteBudget{remaining: maxBytes}
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return tw.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if err := budget.take(hdr.Size); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("write tar: %w", err)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("copy tar entry %q: %w", hdr.Name, err)
+		}
+	}
+}
+
+// tarToZip converts a downloaded tar stream into a zip. Symlinks are
+// stored the way zip tools expect: the target as content, with the
+// symlink mode bit set.
+func tarToZip(dst io.Writer, src io.Reader, maxBytes int64) error {
+	budget := &byteBudget{remaining: maxBytes}
+	tr := tar.NewReader(src)
+	zw := zip.NewWriter(dst)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return zw.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+
+		fh, err := zip.FileInfoHeader(hdr.FileInfo())
+		if err != nil {
+			return fmt.Errorf("zip header %q: %w", hdr.Name, err)
+		}
+		fh.Name = strings.TrimSuffix(hdr.Name, "/")
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			fh.Name += "/"
+			if _, err := zw.CreateHeader(fh); err != nil {
+				return fmt.Errorf("write zip: %w", err)
+			}
+		case tar.TypeReg:
+			if err := budget.take(hdr.Size); err != nil {
+				return err
+			}
+			fh.Method = zip.Deflate
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				return fmt.Errorf("write zip: %w", err)
+			}
+			if _, err := io.Copy(w, tr); err != nil {
+				return fmt.Errorf("copy tar entry %q: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			fh.SetMode(fs.ModeSymlink | 0o777)
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				return fmt.Errorf("write zip: %w", err)
+			}
+			if _, err := io.WriteString(w, hdr.Linkname); err != nil {
+				return fmt.Errorf("write zip: %w", err)
+			}
+		default:
+			// Hard links, devices and fifos have no zip equivalent
+		}
+	}
+}
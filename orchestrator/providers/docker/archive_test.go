@@ -0,0 +1,248 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name, body, link string
+	typ              byte
+	mode             int64 // 0o644 if unset
+}
+
+func buildTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Mode: e.mode, Linkname: e.link}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+		if e.typ == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func tarNames(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestSafeEntryName(t *testing.T) {
+	ok := map[string]string{
+		"world/level.dat":       "world/level.dat",
+		"./world//region/":      "world/region",
+		`plugins\config.yml`:    "plugins/config.yml",
+		"world/../server.jar":   "server.jar",
+		"a/b/../../c/level.dat": "c/level.dat",
+	}
+	for in, want := range ok {
+		got, err := safeEntryName(in)
+		if err != nil || got != want {
+			t.Errorf("safeEntryName(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"/etc/passwd", "../escape", "world/../../escape", `..\escape`} {
+		if _, err := safeEntryName(bad); err == nil {
+			t.Errorf("safeEntryName(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSanitizeTar(t *testing.T) {
+	src := buildTar(t,
+		tarEntry{name: "world/", typ: tar.TypeDir},
+		tarEntry{name: "./world/level.dat", body: "level", typ: tar.TypeReg},
+		tarEntry{name: "world/latest", link: "level.dat", typ: tar.TypeSymlink},
+	)
+	var out bytes.Buffer
+	if err := sanitizeTar(&out, src, 1024); err != nil {
+		t.Fatalf("sanitizeTar: %v", err)
+	}
+	got := strings.Join(tarNames(t, &out), ",")
+	if got != "world/,world/level.dat,world/latest" {
+		t.Errorf("entries = %s", got)
+	}
+
+	rejects := map[string]tarEntry{
+		"traversal":        {name: "../server.properties", body: "x", typ: tar.TypeReg},
+		"absolute":         {name: "/etc/cron.d/job", body: "x", typ: tar.TypeReg},
+		"escaping symlink": {name: "world/evil", link: "../../etc", typ: tar.TypeSymlink},
+		"absolute symlink": {name: "world/evil", link: "/etc", typ: tar.TypeSymlink},
+		"escaping link":    {name: "world/evil", link: "../etc/passwd", typ: tar.TypeLink},
+		"device":           {name: "world/dev", typ: tar.TypeChar},
+	}
+	for name, e := range rejects {
+		if err := sanitizeTar(io.Discard, buildTar(t, e), 1024); err == nil {
+			t.Errorf("%s: sanitizeTar should fail", name)
+		}
+	}
+}
+
+func TestSanitizeTar_SymlinkChains(t *testing.T) {
+	loop := tarEntry{name: "l", link: ".", typ: tar.TypeSymlink}
+	rejects := map[string][]tarEntry{
+		"file through chained links": {
+			loop,
+			{name: "l/m", link: "..", typ: tar.TypeSymlink},
+			{name: "l/m/etc/cron.d/x", body: "x", typ: tar.TypeReg},
+		},
+		"target through link": {
+			loop,
+			{name: "m", link: "l/..", typ: tar.TypeSymlink},
+		},
+		"hard link through link": {
+			loop,
+			{name: "stolen", link: "l/server.properties", typ: tar.TypeLink},
+		},
+		"hard link to link": {
+			loop,
+			{name: "h", link: "l", typ: tar.TypeLink},
+			{name: "h/x", body: "x", typ: tar.TypeReg},
+		},
+	}
+	for name, entries := range rejects {
+		if err := sanitizeTar(io.Discard, buildTar(t, entries...), 1024); err == nil {
+			t.Errorf("%s: sanitizeTar should fail", name)
+		}
+	}
+
+	// Links resolving inside the destination are still fine
+	ok := buildTar(t,
+		tarEntry{name: "world/region/", typ: tar.TypeDir},
+		tarEntry{name: "world/current", link: "region/../region", typ: tar.TypeSymlink},
+		tarEntry{name: "backup", link: "world/current", typ: tar.TypeSymlink},
+	)
+	if err := sanitizeTar(io.Discard, ok, 1024); err != nil {
+		t.Errorf("sanitizeTar: %v", err)
+	}
+}
+
+func TestSanitizeTar_SizeLimit(t *testing.T) {
+	src := buildTar(t,
+		tarEntry{name: "a", body: "12345", typ: tar.TypeReg},
+		tarEntry{name: "b", body: "67890", typ: tar.TypeReg},
+	)
+	if err := sanitizeTar(io.Discard, src, 8); !errors.Is(err, ErrTransferTooLarge) {
+		t.Errorf("err = %v, want ErrTransferTooLarge", err)
+	}
+}
+
+func TestSanitizeTar_StripsSpecialBits(t *testing.T) {
+	src := buildTar(t,
+		tarEntry{name: "start.sh", body: "#!", typ: tar.TypeReg, mode: 0o4755},
+		tarEntry{name: "shared/", typ: tar.TypeDir, mode: 0o3777},
+	)
+	var out bytes.Buffer
+	if err := sanitizeTar(&out, src, 1<<20); err != nil {
+		t.Fatalf("sanitizeTar: %v", err)
+	}
+	want := map[string]int64{"start.sh": 0o755, "shared/": 0o777}
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Mode != want[hdr.Name] {
+			t.Errorf("%s mode = %#o, want %#o", hdr.Name, hdr.Mode, want[hdr.Name])
+		}
+	}
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	src := buildTar(t,
+		tarEntry{name: "world/", typ: tar.TypeDir},
+		tarEntry{name: "world/level.dat", body: "level", typ: tar.TypeReg},
+	)
+	var zipped bytes.Buffer
+	if err := tarToZip(&zipped, src, 1024); err != nil {
+		t.Fatalf("tarToZip: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := zipToTar(&out, zr, 1024); err != nil {
+		t.Fatalf("zipToTar: %v", err)
+	}
+
+	tr := tar.NewReader(&out)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "world/level.dat" {
+			body, _ := io.ReadAll(tr)
+			if string(body) != "level" {
+				t.Errorf("level.dat = %q", body)
+			}
+		}
+	}
+	if got := strings.Join(names, ","); got != "world/,world/level.dat" {
+		t.Errorf("entries = %s", got)
+	}
+}
+
+func TestZipToTar_RejectsTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("../../etc/passwd")
+	io.WriteString(w, "root::0:0")
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zipToTar(io.Discard, zr, 1024); err == nil {
+		t.Error("zipToTar should reject a traversal entry")
+	}
+}
+
+func TestLimitTar(t *testing.T) {
+	src := buildTar(t, tarEntry{name: "world.dat", body: strings.Repeat("x", 100), typ: tar.TypeReg})
+	if err := limitTar(io.Discard, src, 99); !errors.Is(err, ErrTransferTooLarge) {
+		t.Errorf("err = %v, want ErrTransferTooLarge", err)
+	}
+}
@@ -0,0 +1,411 @@
+// Package backup snapshots game server files into compressed tar
+// archives in a local directory and restores them. Snapshots are
+// recorded in a SQLite `backup_snapshots` table so they can be listed
+// and pruned by retention policy:
+//
+//	backup_snapshots(id TEXT PK, server_id TEXT, server_name TEXT, paths TEXT, file TEXT, size_bytes INTEGER, content_bytes INTEGER, sha256 TEXT, note TEXT, created_at TIMESTAMP)
+//
+// Archives store every path relative to the container root, so a
+// snapshot of /data and /plugins restores both in one pass.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/database"
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/providers/docker"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Source is the provider side of a backup. *docker.DockerProvider
+// implements it.
+type Source interface {
+	Get(ctx context.Context, id string) (*orchestrator.Server, error)
+	Mounts(ctx context.Context, id string) ([]string, error)
+	Download(ctx context.Context, id, srcPath string, w io.Writer, opts docker.TransferOptions) error
+	UploadTrusted(ctx context.Context, id, dstDir string, r io.Reader, opts docker.TransferOptions) error
+	ClearPaths(ctx context.Context, id string, paths []string) error
+}
+
+var _ Source = (*docker.DockerProvider)(nil)
+
+// Snapshot is one archive of a server's files.
+type Snapshot struct {
+	bun.BaseModel `bun:"table:backup_snapshots,alias:bs"`
+
+	ID           string    `bun:"id,pk,type:text"                 json:"id"`
+	ServerID     string    `bun:"server_id,notnull,type:text"     json:"server_id"`
+	ServerName   string    `bun:"server_name,type:text"           json:"server_name,omitempty"`
+	Paths        []string  `bun:"paths,type:text"                 json:"paths"`
+	File         string    `bun:"file,notnull,type:text"          json:"file"`
+	SizeBytes    int64     `bun:"size_bytes,notnull"              json:"size_bytes"`    // compressed archive
+	ContentBytes int64     `bun:"content_bytes,notnull"           json:"content_bytes"` // uncompressed file content
+	SHA256       string    `bun:"sha256,notnull,type:text"        json:"sha256"`
+	Note         string    `bun:"note,type:text"                  json:"note,omitempty"`
+	CreatedAt    time.Time `bun:"created_at,nullzero,notnull"     json:"created_at"`
+}
+
+// ErrServerRunning is returned by Restore when the target server is
+// not stopped (running or paused); files must not change underneath a
+// live server.
+var ErrServerRunning = errors.New("backup: server must be stopped to restore")
+
+// DefaultMaxSnapshotBytes caps the uncompressed content of a snapshot
+// unless overridden with WithMaxBytes.
+const DefaultMaxSnapshotBytes = 10 << 30 // 10 GiB
+
+// Migrate creates the backup_snapshots table and its index.
+func Migrate(ctx context.Context, db *bun.DB) error {
+	return database.Migrate(ctx, db,
+		[]interface{}{(*Snapshot)(nil)},
+		[]database.Index{{
+			Name:  "idx_backup_snapshots_server",
+			Query: "CREATE INDEX IF NOT EXISTS idx_backup_snapshots_server ON backup_snapshots (server_id, created_at)",
+		}},
+	)
+}
+
+// Manager creates, lists, restores and prunes snapshots.
+type Manager struct {
+	src      Source
+	db       *bun.DB
+	dir      string
+	maxBytes int64
+	now      func() time.Time
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithMaxBytes limits the uncompressed content of a single snapshot.
+func WithMaxBytes(n int64) Option {
+	return func(m *Manager) {
+		m.maxBytes = n
+	}
+}
+
+// New returns a Manager that writes archives under dir, creating it if
+// needed. The database must have been migrated with Migrate.
+func New(src Source, db *bun.DB, dir string, opts ...Option) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	m := &Manager{
+		src:      src,
+		db:       db,
+		dir:      dir,
+		maxBytes: DefaultMaxSnapshotBytes,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// SnapshotOptions controls Create.
+type SnapshotOptions struct {
+	// Paths to archive inside the container. Empty archives every
+	// mounted volume.
+	Paths []string
+	Note  string
+}
+
+// Create archives a server's files. It works on running servers too,
+// but the game may be writing mid-snapshot: stop it, or flush and pause
+// saving first (e.g. "save-all flush" then "save-off").
+func (m *Manager) Create(ctx context.Context, serverID string, opts SnapshotOptions) (*Snapshot, error) {
+	server, err := m.src.Get(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := append([]string(nil), opts.Paths...)
+	if len(paths) == 0 {
+		if paths, err = m.src.Mounts(ctx, serverID); err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("server %s has no volumes to back up", serverID)
+		}
+	}
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("backup path must be absolute, got %q", p)
+		}
+		paths[i] = path.Clean(p)
+	}
+
+	snap := &Snapshot{
+		ID:         uuid.NewString(),
+		ServerID:   server.ID,
+		ServerName: strings.TrimPrefix(server.Name, "/"),
+		Paths:      paths,
+		Note:       opts.Note,
+		CreatedAt:  m.now().UTC(),
+	}
+
+	serverDir := filepath.Join(m.dir, safeFileName(server.ID))
+	if err := os.MkdirAll(serverDir, 0o750); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	snap.File = filepath.Join(serverDir, snap.ID+".tar.gz")
+
+	// Write to a temporary name so a failed snapshot never looks complete
+	tmp := snap.File + ".partial"
+	if err := m.writeArchive(ctx, serverID, paths, tmp, snap); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, snap.File); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("finalize archive: %w", err)
+	}
+
+	if _, err := m.db.NewInsert().Model(snap).Exec(ctx); err != nil {
+		os.Remove(snap.File)
+		return nil, fmt.Errorf("record snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// writeArchive streams each path out of the container into one gzipped
+// tar, renaming entries to be relative to the container root.
+func (m *Manager) writeArchive(ctx context.Context, serverID string, paths []string, file string, snap *Snapshot) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+
+	remaining := m.maxBytes
+	for _, p := range paths {
+		if remaining <= 0 {
+			return docker.ErrTransferTooLarge
+		}
+		n, err := m.appendPath(ctx, tw, serverID, p, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= n
+		snap.ContentBytes += n
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	snap.SizeBytes = counter.n
+	snap.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// appendPath copies one container path into tw and returns the bytes of
+// file content written.
+func (m *Manager) appendPath(ctx context.Context, tw *tar.Writer, serverID, p string, limit int64) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(m.src.Download(ctx, serverID, p, pw, docker.TransferOptions{
+			Format:   docker.ArchiveTar,
+			MaxBytes: limit,
+		}))
+	}()
+	defer pr.Close()
+
+	// Download names entries relative to the path's parent
+	prefix := strings.TrimPrefix(path.Dir(p), "/")
+
+	var written int64
+	tr := tar.NewReader(pr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("snapshot %s: %w", p, err)
+		}
+		name := path.Join(prefix, hdr.Name)
+		if strings.HasSuffix(hdr.Name, "/") {
+			name += "/"
+		}
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return written, fmt.Errorf("write archive: %w", err)
+		}
+		n, err := io.Copy(tw, tr)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("snapshot %s: %w", p, err)
+		}
+	}
+}
+
+// List returns a server's snapshots, newest first.
+func (m *Manager) List(ctx context.Context, serverID string) ([]Snapshot, error) {
+	var snaps []Snapshot
+	err := m.db.NewSelect().
+		Model(&snaps).
+		Where("server_id = ?", serverID).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+	return snaps, nil
+}
+
+// Get returns a snapshot by ID, or orchestrator.ErrNotFound.
+func (m *Manager) Get(ctx context.Context, id string) (*Snapshot, error) {
+	snap := new(Snapshot)
+	err := m.db.NewSelect().Model(snap).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("snapshot %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// Delete removes a snapshot's archive and record.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	snap, err := m.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return m.delete(ctx, snap)
+}
+
+func (m *Manager) delete(ctx context.Context, snap *Snapshot) error {
+	if err := os.Remove(snap.File); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove archive: %w", err)
+	}
+	if _, err := m.db.NewDelete().Model(snap).WherePK().Exec(ctx); err != nil {
+		return fmt.Errorf("delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// RestoreOptions controls Restore.
+type RestoreOptions struct {
+	// KeepExisting extracts over the server's current files instead of
+	// emptying the snapshot's paths first, so files created since the
+	// snapshot survive.
+	KeepExisting bool
+}
+
+// Restore extracts a snapshot into a stopped server, which may be the
+// server it was taken from or another one with the same layout. Unless
+// opts.KeepExisting is set each snapshot path is emptied first, which
+// needs the paths to be on the server's volumes (as the default, every
+// mount, always is). Entries keep the ownership, modes and symlinks
+// they were archived with. The archive's checksum is verified first.
+func (m *Manager) Restore(ctx context.Context, snapshotID, serverID string, opts RestoreOptions) error {
+	snap, err := m.Get(ctx, snapshotID)
+	if err != nil {
+		return err
+	}
+
+	server, err := m.src.Get(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	if server.Status != orchestrator.StatusStopped {
+		return fmt.Errorf("server %s is %s: %w", serverID, server.Status, ErrServerRunning)
+	}
+
+	if err := verifyChecksum(snap); err != nil {
+		return err
+	}
+
+	f, err := os.Open(snap.File)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	defer gz.Close()
+
+	if !opts.KeepExisting {
+		if err := m.src.ClearPaths(ctx, serverID, snap.Paths); err != nil {
+			return fmt.Errorf("clear restore paths: %w", err)
+		}
+	}
+	// The archive was written by Create and its checksum matches, so its
+	// headers are trusted as is
+	return m.src.UploadTrusted(ctx, serverID, "/", gz, docker.TransferOptions{
+		Format:   docker.ArchiveTar,
+		MaxBytes: snap.ContentBytes + 1,
+	})
+}
+
+func verifyChecksum(snap *Snapshot) error {
+	f, err := os.Open(snap.File)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != snap.SHA256 {
+		return fmt.Errorf("snapshot %s: archive checksum mismatch", snap.ID)
+	}
+	return nil
+}
+
+// safeFileName keeps server IDs usable as directory names.
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,303 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/providers/docker"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+// fakeSource serves files from an in-memory tree and records uploads.
+type fakeSource struct {
+	status   orchestrator.ServerStatus
+	files    map[string]string // container path -> content
+	links    map[string]string // container path -> symlink target
+	uid, gid int               // owner of every file
+
+	uploaded map[string]string      // archive entry -> content
+	headers  map[string]*tar.Header // archive entry -> header as uploaded
+	dstDir   string
+	cleared  []string
+}
+
+func (f *fakeSource) Get(ctx context.Context, id string) (*orchestrator.Server, error) {
+	if id != "mc-1" && id != "mc-2" {
+		return nil, orchestrator.ErrNotFound
+	}
+	return &orchestrator.Server{ID: id, Name: "/" + id, Status: f.status}, nil
+}
+
+func (f *fakeSource) Mounts(ctx context.Context, id string) ([]string, error) {
+	return []string{"/data"}, nil
+}
+
+func (f *fakeSource) Download(ctx context.Context, id, srcPath string, w io.Writer, opts docker.TransferOptions) error {
+	tw := tar.NewWriter(w)
+	parent := path.Dir(srcPath)
+	for name, body := range f.files {
+		if !strings.HasPrefix(name, srcPath+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, parent), "/")
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body)), Uid: f.uid, Gid: f.gid}); err != nil {
+			return err
+		}
+		io.WriteString(tw, body)
+	}
+	for name, target := range f.links {
+		if !strings.HasPrefix(name, srcPath+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, parent), "/")
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Typeflag: tar.TypeSymlink, Linkname: target, Mode: 0o777, Uid: f.uid, Gid: f.gid}); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (f *fakeSource) ClearPaths(ctx context.Context, id string, paths []string) error {
+	f.cleared = append(f.cleared, paths...)
+	return nil
+}
+
+func (f *fakeSource) UploadTrusted(ctx context.Context, id, dstDir string, r io.Reader, opts docker.TransferOptions) error {
+	f.dstDir = dstDir
+	f.uploaded = map[string]string{}
+	f.headers = map[string]*tar.Header{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(tr)
+		f.uploaded[hdr.Name] = string(body)
+		f.headers[hdr.Name] = hdr
+	}
+}
+
+func setupManager(t *testing.T, src Source) *Manager {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqldb.Close() })
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	m, err := New(src, db, t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	src := &fakeSource{
+		status: orchestrator.StatusRunning,
+		files: map[string]string{
+			"/data/world/level.dat":      "level",
+			"/data/server.properties":    "motd=hi",
+			"/plugins/essentials/config": "home: true",
+		},
+	}
+	m := setupManager(t, src)
+	ctx := context.Background()
+
+	snap, err := m.Create(ctx, "mc-1", SnapshotOptions{Paths: []string{"/data", "/plugins/"}, Note: "nightly"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if snap.ServerName != "mc-1" || snap.SizeBytes == 0 || snap.SHA256 == "" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if snap.ContentBytes != int64(len("level")+len("motd=hi")+len("home: true")) {
+		t.Errorf("ContentBytes = %d", snap.ContentBytes)
+	}
+	if _, err := os.Stat(snap.File); err != nil {
+		t.Fatalf("archive missing: %v", err)
+	}
+
+	got, err := m.Get(ctx, snap.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if strings.Join(got.Paths, ",") != "/data,/plugins" {
+		t.Errorf("Paths = %v", got.Paths)
+	}
+
+	for _, status := range []orchestrator.ServerStatus{orchestrator.StatusRunning, orchestrator.StatusPaused} {
+		src.status = status
+		if err := m.Restore(ctx, snap.ID, "mc-2", RestoreOptions{}); !errors.Is(err, ErrServerRunning) {
+			t.Fatalf("Restore %s: %v, want ErrServerRunning", status, err)
+		}
+	}
+	if src.uploaded != nil {
+		t.Fatal("restored into a live server")
+	}
+
+	src.status = orchestrator.StatusStopped
+	if err := m.Restore(ctx, snap.ID, "mc-2", RestoreOptions{}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if src.dstDir != "/" {
+		t.Errorf("restored into %q, want /", src.dstDir)
+	}
+	if got := strings.Join(src.cleared, ","); got != "/data,/plugins" {
+		t.Errorf("cleared %s before restoring, want /data,/plugins", got)
+	}
+	want := map[string]string{
+		"data/world/level.dat":      "level",
+		"data/server.properties":    "motd=hi",
+		"plugins/essentials/config": "home: true",
+	}
+	for name, body := range want {
+		if src.uploaded[name] != body {
+			t.Errorf("restored %s = %q, want %q", name, src.uploaded[name], body)
+		}
+	}
+}
+
+func TestRestoreKeepsOwnershipAndLinks(t *testing.T) {
+	src := &fakeSource{
+		status: orchestrator.StatusStopped,
+		files:  map[string]string{"/data/level.dat": "level"},
+		links:  map[string]string{"/data/logs": "/var/log/game"},
+		uid:    1000,
+		gid:    1000,
+	}
+	m := setupManager(t, src)
+	ctx := context.Background()
+
+	snap, err := m.Create(ctx, "mc-1", SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := m.Restore(ctx, snap.ID, "mc-1", RestoreOptions{KeepExisting: true}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if src.cleared != nil {
+		t.Errorf("KeepExisting cleared %v", src.cleared)
+	}
+
+	file, link := src.headers["data/level.dat"], src.headers["data/logs"]
+	if file == nil || file.Uid != 1000 || file.Gid != 1000 {
+		t.Errorf("level.dat header = %+v, want owner 1000:1000", file)
+	}
+	if link == nil || link.Typeflag != tar.TypeSymlink || link.Linkname != "/var/log/game" || link.Uid != 1000 {
+		t.Errorf("logs header = %+v, want symlink to /var/log/game owned by 1000", link)
+	}
+}
+
+func TestRestoreDetectsCorruption(t *testing.T) {
+	src := &fakeSource{
+		status: orchestrator.StatusStopped,
+		files:  map[string]string{"/data/level.dat": "level"},
+	}
+	m := setupManager(t, src)
+	ctx := context.Background()
+
+	snap, err := m.Create(ctx, "mc-1", SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := os.WriteFile(snap.File, []byte("garbage"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Restore(ctx, snap.ID, "mc-1", RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Restore = %v, want checksum error", err)
+	}
+	if src.cleared != nil {
+		t.Error("cleared the server before the archive was verified")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	src := &fakeSource{
+		status: orchestrator.StatusStopped,
+		files:  map[string]string{"/data/level.dat": "level"},
+	}
+	m := setupManager(t, src)
+	ctx := context.Background()
+
+	base := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		at := base.Add(time.Duration(i) * 24 * time.Hour)
+		m.now = func() time.Time { return at }
+		if _, err := m.Create(ctx, "mc-1", SnapshotOptions{Note: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	deleted, err := m.Prune(ctx, "mc-1", RetentionPolicy{KeepLast: 2})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(deleted) != 3 {
+		t.Fatalf("deleted %d, want 3", len(deleted))
+	}
+	for _, s := range deleted {
+		if _, err := os.Stat(s.File); !os.IsNotExist(err) {
+			t.Errorf("archive %s should be removed", s.File)
+		}
+	}
+	left, _ := m.List(ctx, "mc-1")
+	if len(left) != 2 || left[0].Note != "4" || left[1].Note != "3" {
+		t.Errorf("remaining = %+v", left)
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC)
+	at := func(id string, ago time.Duration) Snapshot {
+		return Snapshot{ID: id, CreatedAt: now.Add(-ago)}
+	}
+	snaps := []Snapshot{
+		at("today-late", 1*time.Hour),
+		at("today-early", 10*time.Hour),
+		at("yesterday-late", 20*time.Hour),
+		at("yesterday-early", 30*time.Hour),
+		at("two-days", 48*time.Hour),
+		at("old", 10*24*time.Hour),
+	}
+
+	cases := []struct {
+		name   string
+		policy RetentionPolicy
+		want   string
+	}{
+		{"zero keeps all", RetentionPolicy{}, ""},
+		{"keep last", RetentionPolicy{KeepLast: 4}, "two-days,old"},
+		{"daily", RetentionPolicy{KeepDailyDays: 2}, "today-early,yesterday-early,two-days,old"},
+		{"combined", RetentionPolicy{KeepLast: 2, KeepDailyDays: 3}, "yesterday-early,old"},
+	}
+	for _, c := range cases {
+		var ids []string
+		for _, s := range c.policy.Expired(snaps, now) {
+			ids = append(ids, s.ID)
+		}
+		if got := strings.Join(ids, ","); got != c.want {
+			t.Errorf("%s: expired = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots Prune keeps. A snapshot is
+// kept if any rule keeps it. The zero policy keeps everything.
+type RetentionPolicy struct {
+	// KeepLast keeps the newest N snapshots.
+	KeepLast int `json:"keep_last,omitempty" yaml:"keep_last,omitempty"`
+	// KeepDailyDays keeps the newest snapshot of each of the last D days
+	// (UTC), today included.
+	KeepDailyDays int `json:"keep_daily_days,omitempty" yaml:"keep_daily_days,omitempty"`
+}
+
+// Prune applies policy to a server's snapshots and deletes the ones it
+// doesn't keep. Returns the snapshots deleted.
+func (m *Manager) Prune(ctx context.Context, serverID string, policy RetentionPolicy) ([]Snapshot, error) {
+	snaps, err := m.List(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := policy.Expired(snaps, m.now())
+	for i := range expired {
+		if err := m.delete(ctx, &expired[i]); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+// Expired returns the snapshots policy doesn't keep as of now.
+func (p RetentionPolicy) Expired(snaps []Snapshot, now time.Time) []Snapshot {
+	if p.KeepLast <= 0 && p.KeepDailyDays <= 0 {
+		return nil
+	}
+
+	sorted := make([]Snapshot, len(snaps))
+	copy(sorted, snaps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	keep := make(map[string]bool)
+	for i := 0; i < p.KeepLast && i < len(sorted); i++ {
+		keep[sorted[i].ID] = true
+	}
+
+	if p.KeepDailyDays > 0 {
+		today := now.UTC().Truncate(24 * time.Hour)
+		oldest := today.AddDate(0, 0, -(p.KeepDailyDays - 1))
+		seenDay := make(map[time.Time]bool)
+		for _, s := range sorted {
+			day := s.CreatedAt.UTC().Truncate(24 * time.Hour)
+			if day.Before(oldest) || seenDay[day] {
+				continue
+			}
+			seenDay[day] = true
+			keep[s.ID] = true
+		}
+	}
+
+	var expired []Snapshot
+	for _, s := range sorted {
+		if !keep[s.ID] {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
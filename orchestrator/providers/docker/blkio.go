@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// WithBlockDevices sets the block devices disk I/O limits are applied
+// to (e.g. "/dev/vda"), skipping detection. Use it when the daemon is
+// remote or detection picks the wrong disk.
+func WithBlockDevices(paths ...string) Option {
+	return func(d *DockerProvider) {
+		d.blockDevices = paths
+	}
+}
+
+// throttleDevices returns the whole-disk block devices that disk I/O
+// limits must be applied to: the disks backing Docker's data root (the
+// container's writable layer) and every bind-mounted host path in
+// volumes. Detection reads this host's /sys and /proc, so it assumes
+// the daemon runs on the same machine.
+func (d *DockerProvider) throttleDevices(ctx context.Context, volumes map[string]string) ([]string, error) {
+	if len(d.blockDevices) > 0 {
+		return d.blockDevices, nil
+	}
+
+	root, err := d.dataRoot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve docker data root: %w", err)
+	}
+
+	paths := []string{root}
+	for host := range volumes {
+		// Named volumes live under the data root; only bind mounts add disks
+		if filepath.IsAbs(host) {
+			paths = append(paths, host)
+		}
+	}
+
+	seen := map[string]bool{}
+	var devices []string
+	for _, p := range paths {
+		dev, err := blockDeviceFor(p)
+		if err != nil {
+			return nil, fmt.Errorf("detect block device for %s (set docker.WithBlockDevices to override): %w", p, err)
+		}
+		if !seen[dev] {
+			seen[dev] = true
+			devices = append(devices, dev)
+		}
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// dataRoot returns the daemon's DockerRootDir, cached after the first
+// successful lookup.
+func (d *DockerProvider) dataRoot(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	root := d.rootDir
+	d.mu.Unlock()
+	if root != "" {
+		return root, nil
+	}
+
+	// Looked up without holding d.mu; concurrent callers may both ask the
+	// daemon, but get the same answer
+	info, err := d.client.Info(ctx)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	d.mu.Lock()
+	d.rootDir = info.DockerRootDir
+	d.mu.Unlock()
+	return info.DockerRootDir, nil
+}
@@ -0,0 +1,204 @@
+//go:build linux
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	sysDevBlock   = "/sys/dev/block"
+	mountInfoPath = "/proc/self/mountinfo"
+)
+
+// mountInfo is one line of /proc/<pid>/mountinfo.
+type mountInfo struct {
+	MajorMinor string // device number as reported by the kernel, e.g. "253:1"
+	MountPoint string
+	FSType     string
+	Source     string // e.g. /dev/vda1, /dev/mapper/vg-docker, overlay
+}
+
+// blockDeviceFor resolves the whole-disk block device (e.g. /dev/vda,
+// /dev/nvme0n1, /dev/dm-0) holding path. Partitions map to their parent
+// disk since the kernel only throttles whole devices; device-mapper
+// (LVM) volumes are throttled at the dm device, where their I/O is
+// submitted.
+func blockDeviceFor(path string) (string, error) {
+	// The daemon creates missing bind sources on the filesystem of their
+	// nearest existing parent, so that is the disk to throttle
+	path, err := existingParent(path)
+	if err != nil {
+		return "", err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", err
+	}
+	major, minor := devMajorMinor(uint64(st.Dev))
+
+	// Filesystems without a single backing device (btrfs, overlay, zfs)
+	// report an anonymous major 0; fall back to the mount's source device.
+	if major == 0 {
+		mounts, err := readMountInfo()
+		if err != nil {
+			return "", err
+		}
+		m, ok := mountFor(mounts, path)
+		if !ok || !strings.HasPrefix(m.Source, "/dev/") {
+			return "", fmt.Errorf("%s is on %s (%s), not a block device", path, m.FSType, m.Source)
+		}
+		if err := syscall.Stat(m.Source, &st); err != nil {
+			return "", err
+		}
+		major, minor = devMajorMinor(uint64(st.Rdev))
+	}
+
+	return wholeDisk(major, minor)
+}
+
+// existingParent returns path, or its closest ancestor that exists.
+func existingParent(path string) (string, error) {
+	path = filepath.Clean(path)
+	for {
+		_, err := os.Stat(path)
+		if err == nil || !os.IsNotExist(err) {
+			return path, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
+
+// wholeDisk maps a device number to the /dev path of its whole disk
+// using sysfs.
+func wholeDisk(major, minor uint32) (string, error) {
+	dir := filepath.Join(sysDevBlock, fmt.Sprintf("%d:%d", major, minor))
+
+	// A partition's sysfs node sits inside its parent disk's node
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Dir(resolved)
+	}
+
+	name, err := ueventDevName(filepath.Join(dir, "uevent"))
+	if err != nil {
+		return "", err
+	}
+	return "/dev/" + name, nil
+}
+
+// ueventDevName reads DEVNAME from a sysfs uevent file.
+func ueventDevName(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, ok := strings.CutPrefix(line, "DEVNAME="); ok {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no DEVNAME in %s", path)
+}
+
+func readMountInfo() ([]mountInfo, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// parseMountInfo parses the mountinfo format described in proc(5):
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// Optional fields run until the "-" separator.
+func parseMountInfo(r io.Reader) ([]mountInfo, error) {
+	var mounts []mountInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+		mounts = append(mounts, mountInfo{
+			MajorMinor: fields[2],
+			MountPoint: unescapeMountPath(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountPath(fields[sep+2]),
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// mountFor returns the mount containing path: the one with the longest
+// matching mount point. Later mounts shadow earlier ones at the same point.
+func mountFor(mounts []mountInfo, path string) (mountInfo, bool) {
+	path = filepath.Clean(path)
+	var best mountInfo
+	found := false
+	for _, m := range mounts {
+		if m.MountPoint != "/" && path != m.MountPoint && !strings.HasPrefix(path, m.MountPoint+"/") {
+			continue
+		}
+		if !found || len(m.MountPoint) >= len(best.MountPoint) {
+			best = m
+			found = true
+		}
+	}
+	return best, found
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space etc.) the
+// kernel uses in mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// devMajorMinor splits a Linux dev_t using the kernel's encoding (see
+// gnu_dev_major/gnu_dev_minor).
+func devMajorMinor(dev uint64) (uint32, uint32) {
+	major := uint32((dev>>8)&0xfff) | uint32((dev>>32)&^0xfff)
+	minor := uint32(dev&0xff) | uint32((dev>>12)&^0xff)
+	return major, minor
+}
@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleMountInfo = `22 1 253:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw
+25 22 0:21 / /proc rw,nosuid shared:12 - proc proc rw
+40 22 253:16 / /var/lib/docker rw,relatime shared:20 - xfs /dev/mapper/vg-docker rw,pquota
+41 22 0:45 / /srv/game\040worlds rw,relatime shared:21 master:3 - btrfs /dev/nvme0n1p2 rw,space_cache
+42 40 0:50 / /var/lib/docker/overlay2/abc/merged rw - overlay overlay rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatalf("parseMountInfo: %v", err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("got %d mounts, want 5", len(mounts))
+	}
+
+	m := mounts[3]
+	if m.MountPoint != "/srv/game worlds" {
+		t.Errorf("MountPoint = %q, want unescaped space", m.MountPoint)
+	}
+	if m.FSType != "btrfs" || m.Source != "/dev/nvme0n1p2" || m.MajorMinor != "0:45" {
+		t.Errorf("optional fields not skipped: %+v", m)
+	}
+}
+
+func TestMountFor(t *testing.T) {
+	mounts, _ := parseMountInfo(strings.NewReader(sampleMountInfo))
+
+	cases := map[string]string{
+		"/var/lib/docker":                 "/dev/mapper/vg-docker",
+		"/var/lib/docker/volumes/x/_data": "/dev/mapper/vg-docker",
+		"/var/lib/dockerish":              "/dev/vda1",
+		"/srv/game worlds/lobby":          "/dev/nvme0n1p2",
+		"/home/user":                      "/dev/vda1",
+	}
+	for path, want := range cases {
+		m, ok := mountFor(mounts, path)
+		if !ok || m.Source != want {
+			t.Errorf("mountFor(%q) = %q, want %q", path, m.Source, want)
+		}
+	}
+}
+
+func TestExistingParent(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		dir:                                  dir,
+		filepath.Join(dir, "worlds/lobby"):   dir,
+		filepath.Join(dir, "worlds") + "/..": dir,
+	}
+	for path, want := range cases {
+		got, err := existingParent(path)
+		if err != nil || got != want {
+			t.Errorf("existingParent(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+}
+
+func TestDevMajorMinor(t *testing.T) {
+	// makedev(259, 3) and makedev(8, 1)
+	cases := []struct {
+		dev          uint64
+		major, minor uint32
+	}{
+		{0x10303, 259, 3},
+		{0x801, 8, 1},
+	}
+	for _, c := range cases {
+		major, minor := devMajorMinor(c.dev)
+		if major != c.major || minor != c.minor {
+			t.Errorf("devMajorMinor(%#x) = %d:%d, want %d:%d", c.dev, major, minor, c.major, c.minor)
+		}
+	}
+}
+
+// fakeSysfs lays out a sysfs tree whose dev/block links point into
+// devices, the way the kernel's do, and points sysDevBlock at it.
+func fakeSysfs(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	nodes := []struct {
+		dev, dir, name string
+		partition      bool
+	}{
+		{"8:0", "devices/pci0000:00/ata1/block/sda", "sda", false},
+		{"8:1", "devices/pci0000:00/ata1/block/sda/sda1", "sda1", true},
+		{"259:0", "devices/pci0000:00/nvme/nvme0/nvme0n1", "nvme0n1", false},
+		{"259:2", "devices/pci0000:00/nvme/nvme0/nvme0n1/nvme0n1p2", "nvme0n1p2", true},
+		{"253:0", "devices/virtual/block/dm-0", "dm-0", false},
+	}
+	blockDir := filepath.Join(root, "dev", "block")
+	if err := os.MkdirAll(blockDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		files := map[string]string{"uevent": "MAJOR=0\nDEVNAME=" + n.name + "\n"}
+		if n.partition {
+			files["partition"] = "1\n"
+		}
+		writeCgroup(t, filepath.Join(root, n.dir), files)
+		if err := os.Symlink(filepath.Join("..", "..", n.dir), filepath.Join(blockDir, n.dev)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := sysDevBlock
+	sysDevBlock = blockDir
+	t.Cleanup(func() { sysDevBlock = old })
+}
+
+func TestWholeDisk(t *testing.T) {
+	fakeSysfs(t)
+
+	cases := []struct {
+		major, minor uint32
+		want         string
+	}{
+		{8, 0, "/dev/sda"},
+		{8, 1, "/dev/sda"},       // partition maps to its disk
+		{259, 0, "/dev/nvme0n1"}, // nvme namespace is the disk
+		{259, 2, "/dev/nvme0n1"}, // namespace partition
+		{253, 0, "/dev/dm-0"},    // device-mapper is throttled as is
+	}
+	for _, c := range cases {
+		got, err := wholeDisk(c.major, c.minor)
+		if err != nil || got != c.want {
+			t.Errorf("wholeDisk(%d:%d) = %q, %v, want %q", c.major, c.minor, got, err, c.want)
+		}
+	}
+	if got, err := wholeDisk(9, 9); err == nil {
+		t.Errorf("wholeDisk(9:9) = %q for a missing device", got)
+	}
+}
+
+func TestReadMountInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(sampleMountInfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := mountInfoPath
+	mountInfoPath = path
+	t.Cleanup(func() { mountInfoPath = old })
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		t.Fatalf("readMountInfo: %v", err)
+	}
+	// An overlay mount has no block device behind it
+	if m, ok := mountFor(mounts, "/var/lib/docker/overlay2/abc/merged/data"); !ok || m.FSType != "overlay" {
+		t.Errorf("overlay path = %+v", m)
+	}
+
+	mountInfoPath = filepath.Join(t.TempDir(), "missing")
+	if _, err := readMountInfo(); err == nil {
+		t.Error("readMountInfo of a missing file succeeded")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package docker
+
+import "errors"
+
+// blockDeviceFor needs Linux sysfs; elsewhere devices must be configured
+// with WithBlockDevices.
+func blockDeviceFor(path string) (string, error) {
+	return "", errors.New("block device detection is only supported on linux")
+}
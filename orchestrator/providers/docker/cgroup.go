@@ -0,0 +1,443 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// WithCgroupStats makes Stats and StatsAll read usage straight from the
+// cgroup v2 hierarchy mounted at root ("" for /sys/fs/cgroup) and from
+// /proc, instead of the daemon's stats API. StatsAll then costs a single
+// ContainerList however many servers are running, which matters on
+// dense hosts. Requires the daemon to run on this machine with cgroup
+// v2 (unified) mode.
+//
+// CPU percent is computed between successive calls, so it reads 0 the
+// first time a container is seen.
+func WithCgroupStats(root string) Option {
+	return func(d *DockerProvider) {
+		if root == "" {
+			root = defaultCgroupRoot
+		}
+		d.cgroups = newCgroupReader(root, "/proc")
+	}
+}
+
+// cgroupReader reads container usage from cgroup v2 files.
+type cgroupReader struct {
+	root     string
+	procRoot string
+	now      func() time.Time
+
+	mu    sync.Mutex
+	paths map[string]string    // container ID -> cgroup directory
+	cpu   map[string]cpuSample // previous cpu.stat reading per container
+	total int64                // host MemTotal, for containers without a limit
+}
+
+type cpuSample struct {
+	usageUsec uint64
+	at        time.Time
+}
+
+func newCgroupReader(root, procRoot string) *cgroupReader {
+	return &cgroupReader{
+		root:     root,
+		procRoot: procRoot,
+		now:      time.Now,
+		paths:    make(map[string]string),
+		cpu:      make(map[string]cpuSample),
+	}
+}
+
+// statsAllCgroup lists running containers once and reads each one's
+// cgroup. Containers whose cgroup can't be read are skipped, as in
+// StatsAll.
+func (d *DockerProvider) statsAllCgroup(ctx context.Context) ([]ContainerStats, error) {
+	containers, err := d.client.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	running := make(map[string]bool, len(containers))
+	var ids []string
+	for _, c := range containers {
+		if c.State == "running" {
+			running[c.ID] = true
+			ids = append(ids, c.ID)
+		}
+	}
+	// Resolve every container up front so unknown ones cost one walk
+	// per round between them, not one each
+	dirs := d.cgroups.resolve(ids)
+
+	var results []ContainerStats
+	for _, c := range containers {
+		dir, ok := dirs[c.ID]
+		if !ok {
+			continue
+		}
+		name := c.ID[:12]
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+		s, err := d.cgroups.readDir(c.ID, name, dir)
+		if err != nil {
+			continue
+		}
+		results = append(results, *s)
+	}
+	d.cgroups.forget(running)
+	return results, nil
+}
+
+// statsCgroup reads one container, resolving its full ID and name with
+// a single inspect.
+func (d *DockerProvider) statsCgroup(ctx context.Context, id string) (*ContainerStats, error) {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return d.cgroups.read(info.ID, info.Name)
+}
+
+// read assembles a ContainerStats from the container's cgroup files and
+// the network counters of its first process.
+func (r *cgroupReader) read(id, name string) (*ContainerStats, error) {
+	dir, err := r.dir(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.readDir(id, name, dir)
+}
+
+// readDir is read for a container whose cgroup directory is known.
+func (r *cgroupReader) readDir(id, name, dir string) (*ContainerStats, error) {
+	usage, err := readCPUUsage(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	memUsed, err := readInt(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	memLimit, err := r.memoryLimit(dir)
+	if err != nil {
+		return nil, err
+	}
+	diskRead, diskWrite, err := readIOStat(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return nil, err
+	}
+	netRx, netTx, err := r.netCounters(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+	return &ContainerStats{
+		ContainerID:    id,
+		Name:           name,
+		CPUPercent:     r.cpuPercent(id, usage, now),
+		MemoryUsed:     memUsed,
+		MemoryLimit:    memLimit,
+		NetRxBytes:     netRx,
+		NetTxBytes:     netTx,
+		DiskReadBytes:  diskRead,
+		DiskWriteBytes: diskWrite,
+		Timestamp:      now.Unix(),
+	}, nil
+}
+
+// dir finds a container's cgroup directory.
+func (r *cgroupReader) dir(id string) (string, error) {
+	if dir, ok := r.resolve([]string{id})[id]; ok {
+		return dir, nil
+	}
+	return "", fmt.Errorf("cgroup for container %s not found under %s", id, r.root)
+}
+
+// resolve finds the cgroup directories of ids, omitting those that
+// can't be found. The systemd and cgroupfs drivers use fixed layouts;
+// anything else (custom cgroup parents, rootless) is found by a single
+// walk of the hierarchy shared by every miss, made without holding r.mu
+// so other readers aren't stalled behind it.
+func (r *cgroupReader) resolve(ids []string) map[string]string {
+	found := make(map[string]string, len(ids))
+	r.mu.Lock()
+	for _, id := range ids {
+		if dir, ok := r.paths[id]; ok {
+			found[id] = dir
+		}
+	}
+	r.mu.Unlock()
+
+	want := map[string]string{} // directory name -> container ID
+	for _, id := range ids {
+		if dir, ok := found[id]; ok {
+			if _, err := os.Stat(dir); err == nil {
+				continue
+			}
+			delete(found, id)
+		}
+		for _, dir := range []string{
+			filepath.Join(r.root, "system.slice", "docker-"+id+".scope"),
+			filepath.Join(r.root, "docker", id),
+		} {
+			if _, err := os.Stat(dir); err == nil {
+				found[id] = dir
+				break
+			}
+		}
+		if _, ok := found[id]; !ok {
+			want[id] = id
+			want["docker-"+id+".scope"] = id
+		}
+	}
+
+	if len(want) > 0 {
+		filepath.WalkDir(r.root, func(path string, e fs.DirEntry, err error) error {
+			if err != nil || !e.IsDir() {
+				return nil
+			}
+			id, ok := want[e.Name()]
+			if !ok {
+				return nil
+			}
+			found[id] = path
+			delete(want, id)
+			delete(want, "docker-"+id+".scope")
+			if len(want) == 0 {
+				return fs.SkipAll
+			}
+			// Nothing else lives inside a container's cgroup
+			return fs.SkipDir
+		})
+	}
+
+	r.mu.Lock()
+	for _, id := range ids {
+		if dir, ok := found[id]; ok {
+			r.paths[id] = dir
+		} else {
+			delete(r.paths, id)
+		}
+	}
+	r.mu.Unlock()
+	return found
+}
+
+// cpuPercent converts the cumulative usage_usec into a percentage of one
+// core since the previous reading, matching the daemon's stats API.
+func (r *cgroupReader) cpuPercent(id string, usageUsec uint64, now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.cpu[id]
+	r.cpu[id] = cpuSample{usageUsec: usageUsec, at: now}
+	if !ok || usageUsec < prev.usageUsec {
+		return 0
+	}
+	wall := now.Sub(prev.at).Microseconds()
+	if wall <= 0 {
+		return 0
+	}
+	return float64(usageUsec-prev.usageUsec) / float64(wall) * 100
+}
+
+// forget drops cached state for containers no longer running.
+func (r *cgroupReader) forget(running map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range r.cpu {
+		if !running[id] {
+			delete(r.cpu, id)
+		}
+	}
+	for id := range r.paths {
+		if !running[id] {
+			delete(r.paths, id)
+		}
+	}
+}
+
+// memoryLimit reads memory.max, using the host's total memory for
+// unlimited containers as the daemon does.
+func (r *cgroupReader) memoryLimit(dir string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value != "max" {
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.total == 0 {
+		f, err := os.Open(filepath.Join(r.procRoot, "meminfo"))
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		if r.total, err = parseMemTotal(f); err != nil {
+			return 0, err
+		}
+	}
+	return r.total, nil
+}
+
+// netCounters reads /proc/<pid>/net/dev for the first process in the
+// cgroup, which sees the container's network namespace.
+func (r *cgroupReader) netCounters(dir string) (rx, tx int64, err error) {
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return 0, 0, err
+	}
+	pid, _, _ := strings.Cut(strings.TrimSpace(string(procs)), "\n")
+	if pid == "" {
+		return 0, 0, nil
+	}
+	f, err := os.Open(filepath.Join(r.procRoot, pid, "net", "dev"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+func readInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+}
+
+func readCPUUsage(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseCPUStat(f)
+}
+
+func readIOStat(path string) (read, write int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		// io.stat is absent when the io controller isn't enabled
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer f.Close()
+	return parseIOStat(f)
+}
+
+// parseCPUStat returns usage_usec from a cgroup v2 cpu.stat file.
+func parseCPUStat(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "usage_usec "); ok {
+			return strconv.ParseUint(v, 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no usage_usec in cpu.stat")
+}
+
+// parseIOStat sums rbytes and wbytes across devices in a cgroup v2
+// io.stat file:
+//
+//	8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func parseIOStat(r io.Reader) (read, write int64, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for _, f := range fields[min(1, len(fields)):] {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				write += n
+			}
+		}
+	}
+	return read, write, scanner.Err()
+}
+
+// parseNetDev sums received and transmitted bytes across interfaces in
+// /proc/<pid>/net/dev, skipping loopback like the daemon does.
+func parseNetDev(r io.Reader) (rx, tx int64, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		iface, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		// Receive bytes is the first field, transmit bytes the ninth
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		in, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		out, err := strconv.ParseInt(fields[8], 10, 64)
+		if err != nil {
+			continue
+		}
+		rx += in
+		tx += out
+	}
+	return rx, tx, scanner.Err()
+}
+
+// parseMemTotal returns MemTotal from /proc/meminfo in bytes.
+func parseMemTotal(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		v, ok := strings.CutPrefix(scanner.Text(), "MemTotal:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb << 10, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemTotal in meminfo")
+}
@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    9000      10    0    0    0     0          0         0     9000      10    0    0    0     0       0          0
+  eth0:  123456     200    0    0    0     0          0         0    65432     150    0    0    0     0       0          0
+  eth1:    1000       5    0    0    0     0          0         0      500       3    0    0    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	rx, tx, err := parseNetDev(strings.NewReader(sampleNetDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rx != 124456 || tx != 65932 {
+		t.Errorf("rx, tx = %d, %d; want 124456, 65932 (loopback excluded)", rx, tx)
+	}
+}
+
+func TestParseIOStat(t *testing.T) {
+	const stat = "8:0 rbytes=1000 wbytes=2000 rios=1 wios=2 dbytes=0 dios=0\n" +
+		"253:1 rbytes=500 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n"
+	read, write, err := parseIOStat(strings.NewReader(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 1500 || write != 2000 {
+		t.Errorf("read, write = %d, %d; want 1500, 2000", read, write)
+	}
+}
+
+// writeCgroup lays out the files read for one container under dir.
+func writeCgroup(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupReader(t *testing.T) {
+	root := t.TempDir()
+	proc := t.TempDir()
+	id := strings.Repeat("ab", 32)
+
+	// Custom cgroup parent, found by walking
+	dir := filepath.Join(root, "game.slice", "docker-"+id+".scope")
+	writeCgroup(t, dir, map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 800000\nsystem_usec 200000\n",
+		"memory.current": "268435456\n",
+		"memory.max":     "max\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n",
+		"cgroup.procs":   "4242\n4243\n",
+	})
+	writeCgroup(t, filepath.Join(proc, "4242", "net"), map[string]string{"dev": sampleNetDev})
+	writeCgroup(t, proc, map[string]string{"meminfo": "MemTotal:       16384 kB\nMemFree: 1 kB\n"})
+
+	r := newCgroupReader(root, proc)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	s, err := r.read(id, "/lobby-1")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if s.CPUPercent != 0 {
+		t.Errorf("first CPUPercent = %v, want 0", s.CPUPercent)
+	}
+	if s.MemoryUsed != 256<<20 || s.MemoryLimit != 16<<20 {
+		t.Errorf("memory = %d/%d", s.MemoryUsed, s.MemoryLimit)
+	}
+	if s.DiskReadBytes != 4096 || s.DiskWriteBytes != 8192 {
+		t.Errorf("disk = %d/%d", s.DiskReadBytes, s.DiskWriteBytes)
+	}
+	if s.NetRxBytes != 124456 || s.NetTxBytes != 65932 {
+		t.Errorf("net = %d/%d", s.NetRxBytes, s.NetTxBytes)
+	}
+
+	// 1.5 CPU-seconds over 1 second is 150% (one and a half cores)
+	now = now.Add(time.Second)
+	writeCgroup(t, dir, map[string]string{"cpu.stat": "usage_usec 2500000\n", "memory.max": "536870912\n"})
+	s, err = r.read(id, "/lobby-1")
+	if err != nil {
+		t.Fatalf("second read: %v", err)
+	}
+	if s.CPUPercent != 150 {
+		t.Errorf("CPUPercent = %v, want 150", s.CPUPercent)
+	}
+	if s.MemoryLimit != 512<<20 {
+		t.Errorf("MemoryLimit = %d, want %d", s.MemoryLimit, 512<<20)
+	}
+
+	r.forget(map[string]bool{})
+	if len(r.cpu) != 0 || len(r.paths) != 0 {
+		t.Error("forget kept state for stopped container")
+	}
+
+	if _, err := r.read("missing", "/x"); err == nil {
+		t.Error("read of unknown container succeeded")
+	}
+}
+
+func TestCgroupResolve(t *testing.T) {
+	root := t.TempDir()
+	systemd := strings.Repeat("01", 32)
+	custom := strings.Repeat("cd", 32)
+	writeCgroup(t, filepath.Join(root, "system.slice", "docker-"+systemd+".scope"), nil)
+	writeCgroup(t, filepath.Join(root, "games", "lobby", custom), nil)
+
+	r := newCgroupReader(root, t.TempDir())
+	dirs := r.resolve([]string{systemd, custom, "missing"})
+	if len(dirs) != 2 || dirs[custom] != filepath.Join(root, "games", "lobby", custom) {
+		t.Errorf("resolve = %v", dirs)
+	}
+	if _, ok := r.paths["missing"]; ok || len(r.paths) != 2 {
+		t.Errorf("cached paths = %v", r.paths)
+	}
+
+	// A cached directory that disappeared is looked up again
+	os.RemoveAll(filepath.Join(root, "games"))
+	writeCgroup(t, filepath.Join(root, "docker", custom), nil)
+	if dir, err := r.dir(custom); err != nil || dir != filepath.Join(root, "docker", custom) {
+		t.Errorf("dir after move = %q, %v", dir, err)
+	}
+}
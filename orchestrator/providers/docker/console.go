@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Console is an attached session to a container's main process. Lines
+// passed to Send are written to the server's stdin (Allocate always
+// opens it) and everything the process prints arrives on Output, so
+// operators can drive the live server console (/say, /stop, save-all)
+// rather than spawning a new process the way Exec does.
+type Console struct {
+	conn   types.HijackedResponse
+	cancel context.CancelFunc
+	output chan orchestrator.LogLine
+	errs   chan error
+
+	mu     sync.Mutex // serialises writes so lines never interleave
+	closed bool
+}
+
+// Attach opens a console session on a running container. The session
+// ends when ctx is cancelled, Close is called or the container exits;
+// Output is closed in every case.
+func (d *DockerProvider) Attach(ctx context.Context, id string) (*Console, error) {
+	tty, err := d.requireRunning(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.client.ContainerAttach(ctx, id, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attach failed: %w", wrapConflict(err, orchestrator.ErrNotRunning))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Console{
+		conn:   conn,
+		cancel: cancel,
+		output: make(chan orchestrator.LogLine, 64),
+		errs:   make(chan error, 1),
+	}
+
+	// Unblock the reader when the session is cancelled
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(c.output)
+		defer close(c.errs)
+
+		stdout := newLineWriter(ctx, c.output, orchestrator.StreamStdout, false)
+		stderr := newLineWriter(ctx, c.output, orchestrator.StreamStderr, false)
+
+		var err error
+		if tty {
+			_, err = io.Copy(stdout, conn.Reader)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, conn.Reader)
+		}
+		stdout.Flush()
+		stderr.Flush()
+
+		if err != nil && ctx.Err() == nil {
+			c.errs <- err
+		}
+		cancel()
+	}()
+
+	return c, nil
+}
+
+// Send writes a single console line to the server's stdin. A trailing
+// newline is added if missing.
+func (c *Console) Send(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("console closed")
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	if _, err := io.WriteString(c.conn.Conn, line); err != nil {
+		return fmt.Errorf("console write failed: %w", err)
+	}
+	return nil
+}
+
+// Output returns the server's output, one line per entry. Lines carry
+// the time they were received rather than a daemon timestamp.
+func (c *Console) Output() <-chan orchestrator.LogLine {
+	return c.output
+}
+
+// Err receives the error that ended the session early, if any. It is
+// closed together with Output.
+func (c *Console) Err() <-chan error {
+	return c.errs
+}
+
+// Close detaches from the container. The server keeps running.
+func (c *Console) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.cancel()
+	return nil
+}
+
+// SendCommand writes a single line to a running container's stdin
+// without waiting for output. Use Attach to watch the response.
+func (d *DockerProvider) SendCommand(ctx context.Context, id, command string) error {
+	if _, err := d.requireRunning(ctx, id); err != nil {
+		return err
+	}
+
+	conn, err := d.client.ContainerAttach(ctx, id, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("attach failed: %w", wrapConflict(err, orchestrator.ErrNotRunning))
+	}
+	defer conn.Close()
+
+	if !strings.HasSuffix(command, "\n") {
+		command += "\n"
+	}
+	if _, err := io.WriteString(conn.Conn, command); err != nil {
+		return fmt.Errorf("console write failed: %w", err)
+	}
+	return nil
+}
+
+// requireRunning inspects the container and fails if it isn't running;
+// attaching to a stopped container would block until it next starts.
+// Returns whether the container was created with a TTY.
+func (d *DockerProvider) requireRunning(ctx context.Context, id string) (bool, error) {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return false, wrapErr(err)
+	}
+	if info.State == nil || !info.State.Running {
+		return false, fmt.Errorf("container %s: %w", id, orchestrator.ErrNotRunning)
+	}
+	return info.Config != nil && info.Config.Tty, nil
+}
@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// consoleDaemon serves a single container's inspect, attach, wait and
+// stop endpoints, recording what the provider sends to each.
+type consoleDaemon struct {
+	mu          sync.Mutex
+	running     bool
+	attachFails bool
+	exits       bool     // whether wait reports an exit
+	stops       []string // query strings of stop requests
+
+	stdin chan string // one entry per attach session
+}
+
+func (f *consoleDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	f.mu.Lock()
+	running, attachFails, exits := f.running, f.attachFails, f.exits
+	f.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(path, "/json"):
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{Running: running},
+			},
+			Config: &container.Config{},
+		})
+	case strings.HasSuffix(path, "/attach"):
+		if attachFails {
+			http.Error(w, `{"message":"attach refused"}`, http.StatusInternalServerError)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buf.Flush()
+		data, _ := io.ReadAll(buf)
+		f.stdin <- string(data)
+	case strings.HasSuffix(path, "/wait"):
+		if !exits {
+			http.Error(w, `{"message":"wait failed"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(container.WaitResponse{})
+	case strings.HasSuffix(path, "/stop"):
+		f.mu.Lock()
+		f.stops = append(f.stops, r.URL.RawQuery)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newConsoleProvider(t *testing.T, f *consoleDaemon) *DockerProvider {
+	t.Helper()
+	f.stdin = make(chan string, 4)
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://" + srv.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Built directly so no event stream is opened against the fake
+	return &DockerProvider{client: cli}
+}
+
+func TestSendCommand(t *testing.T) {
+	ctx := context.Background()
+	f := &consoleDaemon{running: true}
+	d := newConsoleProvider(t, f)
+
+	for _, tc := range []struct{ cmd, want string }{
+		{"save-all", "save-all\n"},
+		{"say hi\n", "say hi\n"},
+	} {
+		if err := d.SendCommand(ctx, "server", tc.cmd); err != nil {
+			t.Fatalf("SendCommand(%q): %v", tc.cmd, err)
+		}
+		// The daemon records the session once the provider hangs up
+		select {
+		case got := <-f.stdin:
+			if got != tc.want {
+				t.Errorf("SendCommand(%q) wrote %q, want %q", tc.cmd, got, tc.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("SendCommand(%q) wrote nothing", tc.cmd)
+		}
+	}
+}
+
+func TestSendCommandRequiresRunning(t *testing.T) {
+	f := &consoleDaemon{}
+	d := newConsoleProvider(t, f)
+
+	err := d.SendCommand(context.Background(), "server", "stop")
+	if !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("SendCommand on stopped container = %v, want ErrNotRunning", err)
+	}
+	if len(f.stdin) != 0 {
+		t.Error("attached to a stopped container")
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/bananalabs-oss/potassium/orchestrator"
 	"github.com/docker/docker/api/types/blkiodev"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
@@ -17,29 +19,67 @@ import (
 
 type DockerProvider struct {
 	client *client.Client
+	ports  *orchestrator.PortAllocator
+
+	registryAuth map[string]RegistryAuth // keyed by registry host
+	pullProgress func(image string, p PullProgress)
+	blockDevices []string      // overrides detection when set
+	cgroups      *cgroupReader // set by WithCgroupStats
+	limits       *limitCache   // per-container limits for List
+
+	mu      sync.Mutex
+	rootDir string // cached DockerRootDir
 }
 
-func New() (*DockerProvider, error) {
-	// try create client
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+// Option configures a DockerProvider.
+type Option func(*DockerProvider)
 
-	// if client creation fails
-	if err != nil {
-		return nil, err
+func New(opts ...Option) (*DockerProvider, error) {
+	d := &DockerProvider{
+		ports:        orchestrator.NewPortAllocator(),
+		registryAuth: map[string]RegistryAuth{},
+		limits:       newLimitCache(),
+	}
+	for _, opt := range opts {
+		opt(d)
 	}
 
-	// Return provider
-	return &DockerProvider{
-		client: cli,
-	}, nil
+	// Connect from the environment unless WithClient supplied one
+	if d.client == nil {
+		cli, err := client.NewClientWithOpts(client.FromEnv)
+		if err != nil {
+			return nil, err
+		}
+		d.client = cli
+	}
+	return d, nil
+}
+
+// WithClient uses an existing Docker client instead of one configured
+// from the environment. Close closes it.
+func WithClient(cli *client.Client) Option {
+	return func(d *DockerProvider) {
+		d.client = cli
+	}
 }
 
 // List - takes filter, returns slice
 func (d *DockerProvider) List(ctx context.Context, filter map[string]string) ([]orchestrator.Server, error) {
+	// Only containers we created, narrowed by the caller's label filter
+	args := filters.NewArgs(filters.Arg("label", orchestrator.LabelManaged+"=true"))
+	for key, value := range filter {
+		if value == "" {
+			args.Add("label", key)
+		} else {
+			args.Add("label", key+"="+value)
+		}
+	}
+
 	// Request docker container list
-	c, err := d.client.ContainerList(ctx, container.ListOptions{})
+	d.watchLimits()
+	c, err := d.client.ContainerList(ctx, container.ListOptions{Filters: args})
 	if err != nil {
-		return nil, err
+		return nil, wrapErr(err)
 	}
 
 	// Loop through list of containers
@@ -52,6 +92,8 @@ func (d *DockerProvider) List(ctx context.Context, filter map[string]string) ([]
 			status = orchestrator.StatusRunning
 		case "exited":
 			status = orchestrator.StatusStopped
+		case "paused":
+			status = orchestrator.StatusPaused
 		default:
 			status = orchestrator.StatusError
 		}
@@ -77,16 +119,13 @@ func (d *DockerProvider) List(ctx context.Context, filter map[string]string) ([]
 			Status: status,
 			IP:     ip,
 			Ports:  ports,
+			Labels: c.Labels,
 		}
 
-		// Inspect to get HostConfig limits (used by reconcile)
-		if inspect, err := d.client.ContainerInspect(ctx, c.ID); err == nil {
-			if inspect.HostConfig != nil {
-				server.MemoryLimit = inspect.HostConfig.Memory
-				if inspect.HostConfig.NanoCPUs > 0 {
-					server.CPULimit = float64(inspect.HostConfig.NanoCPUs) / 1e9
-				}
-			}
+		// HostConfig limits (used by reconcile) aren't in the list
+		// response; they're cached so only new containers are inspected
+		if resources, ok := d.containerLimits(ctx, c.ID); ok {
+			applyLimits(&server, resources)
 		}
 
 		servers = append(servers, server)
@@ -100,7 +139,7 @@ func (d *DockerProvider) Get(ctx context.Context, id string) (*orchestrator.Serv
 	// Get container by ID
 	c, err := d.client.ContainerInspect(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, wrapErr(err)
 	}
 
 	// Convert status
@@ -110,6 +149,8 @@ func (d *DockerProvider) Get(ctx context.Context, id string) (*orchestrator.Serv
 		status = orchestrator.StatusRunning
 	case "exited":
 		status = orchestrator.StatusStopped
+	case "paused":
+		status = orchestrator.StatusPaused
 	default:
 		status = orchestrator.StatusError
 	}
@@ -139,11 +180,11 @@ func (d *DockerProvider) Get(ctx context.Context, id string) (*orchestrator.Serv
 		IP:     ip,
 		Ports:  ports,
 	}
+	if c.Config != nil {
+		server.Labels = c.Config.Labels
+	}
 	if c.HostConfig != nil {
-		server.MemoryLimit = c.HostConfig.Memory
-		if c.HostConfig.NanoCPUs > 0 {
-			server.CPULimit = float64(c.HostConfig.NanoCPUs) / 1e9
-		}
+		applyLimits(&server, c.HostConfig.Resources)
 	}
 
 	return &server, nil
@@ -151,6 +192,16 @@ func (d *DockerProvider) Get(ctx context.Context, id string) (*orchestrator.Serv
 
 // Allocate - takes request, returns pointer
 func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.AllocateRequest) (*orchestrator.Server, error) {
+	// Reject unsupported combinations before touching the daemon
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Make sure the image is present according to the pull policy
+	if err := d.ensureImage(ctx, req.Image, req.PullPolicy); err != nil {
+		return nil, err
+	}
+
 	// Build env slice
 	env := []string{}
 	for key, value := range req.Environment {
@@ -167,22 +218,55 @@ func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.Allocate
 	exposedPorts := nat.PortSet{}
 	portBindings := nat.PortMap{}
 
+	// Ports picked from a range stay reserved until the container is
+	// started (or creation fails), after which Docker reports them as bound.
+	var reserved []orchestrator.PortBinding
+	defer func() {
+		for _, r := range reserved {
+			d.ports.Release(r.Protocol, r.Host)
+		}
+	}()
+
+	var used map[string]bool
 	for _, p := range req.Ports {
 		containerPort := nat.Port(fmt.Sprintf("%d/%s", p.Container, p.Protocol))
 		exposedPorts[containerPort] = struct{}{}
+
+		// Only bind to host if not using overlay network
+		if req.Network != "" {
+			continue
+		}
+
+		hostPort := p.Host
+		if p.Range != "" {
+			low, high, err := orchestrator.ParsePortRange(p.Range)
+			if err != nil {
+				return nil, err
+			}
+			if used == nil {
+				used, err = d.usedHostPorts(ctx)
+				if err != nil {
+					return nil, err
+				}
+			}
+			proto := p.Protocol
+			hostPort, err = d.ports.Reserve(proto, low, high, func(port int) bool {
+				return used[hostPortKey(proto, port)] || !orchestrator.PortFree(proto, port)
+			})
+			if err != nil {
+				return nil, err
+			}
+			reserved = append(reserved, orchestrator.PortBinding{Host: hostPort, Protocol: proto})
+		}
+
 		portBindings[containerPort] = []nat.PortBinding{
 			{
 				HostIP:   "",
-				HostPort: fmt.Sprintf("%d", p.Host),
+				HostPort: fmt.Sprintf("%d", hostPort),
 			},
 		}
 	}
 
-	// Only bind to host if not using overlay network
-	if req.Network != "" {
-		portBindings = nat.PortMap{}
-	}
-
 	// Build network config if specified
 	var networkConfig *network.NetworkingConfig
 	if req.Network != "" {
@@ -200,30 +284,28 @@ func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.Allocate
 	}
 
 	// Build resource limits
-	resources := container.Resources{}
-	if req.MemoryLimit > 0 {
-		resources.Memory = req.MemoryLimit
-	}
-	if req.CPULimit > 0 {
-		resources.NanoCPUs = int64(req.CPULimit * 1e9)
-	}
-	if req.PidsLimit > 0 {
-		resources.PidsLimit = &req.PidsLimit
-	}
-	if req.MemorySwap != 0 {
-		resources.MemorySwap = req.MemorySwap
-	}
-	// Disk I/O rate limits (applied to all block devices)
-	if req.DiskIOReadBps > 0 {
-		resources.BlkioDeviceReadBps = []*blkiodev.ThrottleDevice{
-			{Path: "/dev/sda", Rate: uint64(req.DiskIOReadBps)},
-			{Path: "/dev/nvme0n1", Rate: uint64(req.DiskIOReadBps)},
+	resources := resourceLimits(orchestrator.ResourceUpdate{
+		MemoryLimit: req.MemoryLimit,
+		MemorySwap:  req.MemorySwap,
+		CPULimit:    req.CPULimit,
+		PidsLimit:   req.PidsLimit,
+	})
+	// Disk I/O rate limits, applied to the disks backing the container's
+	// writable layer and bind mounts
+	if req.DiskIOReadBps > 0 || req.DiskIOWriteBps > 0 {
+		devices, err := d.throttleDevices(ctx, req.Volumes)
+		if err != nil {
+			return nil, err
 		}
-	}
-	if req.DiskIOWriteBps > 0 {
-		resources.BlkioDeviceWriteBps = []*blkiodev.ThrottleDevice{
-			{Path: "/dev/sda", Rate: uint64(req.DiskIOWriteBps)},
-			{Path: "/dev/nvme0n1", Rate: uint64(req.DiskIOWriteBps)},
+		for _, dev := range devices {
+			if req.DiskIOReadBps > 0 {
+				resources.BlkioDeviceReadBps = append(resources.BlkioDeviceReadBps,
+					&blkiodev.ThrottleDevice{Path: dev, Rate: uint64(req.DiskIOReadBps)})
+			}
+			if req.DiskIOWriteBps > 0 {
+				resources.BlkioDeviceWriteBps = append(resources.BlkioDeviceWriteBps,
+					&blkiodev.ThrottleDevice{Path: dev, Rate: uint64(req.DiskIOWriteBps)})
+			}
 		}
 	}
 
@@ -233,27 +315,57 @@ func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.Allocate
 		storageOpt["size"] = fmt.Sprintf("%d", req.DiskSizeLimit)
 	}
 
+	// Ulimits live alongside the other resource limits
+	for _, u := range req.Ulimits {
+		resources.Ulimits = append(resources.Ulimits, &container.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+
+	config := &container.Config{
+		Image:        req.Image,
+		Env:          env, // Env Slice
+		ExposedPorts: exposedPorts,
+		Labels:       orchestrator.ServerLabels(req),
+		OpenStdin:    true,
+		Cmd:          req.Command,
+		Entrypoint:   req.Entrypoint,
+		WorkingDir:   req.WorkingDir,
+		User:         req.User,
+	}
+	if req.Readiness != nil && req.Readiness.HealthCheck != nil {
+		config.Healthcheck = healthConfig(req.Readiness.HealthCheck)
+	}
+
 	// Create container
 	resp, err := d.client.ContainerCreate(
 		ctx,
-		&container.Config{
-			Image:        req.Image,
-			Env:          env, // Env Slice
-			ExposedPorts: exposedPorts,
-			OpenStdin:    true,
-		},
+		config,
 		&container.HostConfig{
 			Binds:        binds,
 			PortBindings: portBindings,
 			Resources:    resources,
 			StorageOpt:   storageOpt,
+			RestartPolicy: container.RestartPolicy{
+				Name:              container.RestartPolicyMode(req.RestartPolicy),
+				MaximumRetryCount: req.RestartMaxRetries,
+			},
+			Sysctls:        req.Sysctls,
+			Tmpfs:          req.Tmpfs,
+			ReadonlyRootfs: req.ReadOnlyRootfs,
+			CapAdd:         req.CapAdd,
+			CapDrop:        req.CapDrop,
+			SecurityOpt:    req.SecurityOpt,
+			DNS:            req.DNS,
+			ExtraHosts:     req.ExtraHosts,
 		},
 		networkConfig, // Network Config
 		nil,           // Platform
 		req.Name,      // Name (empty → Docker generates one)
 	)
 	if err != nil {
-		return nil, err
+		if isImageNotFound(err) {
+			return nil, wrapImageErr(err)
+		}
+		return nil, wrapConflict(err, orchestrator.ErrAlreadyExists)
 	}
 
 	// Start container
@@ -261,7 +373,15 @@ func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.Allocate
 	if err != nil {
 		// Clean up the created container on start failure
 		d.client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
-		return nil, err
+		return nil, wrapErr(err)
+	}
+
+	// Block until the game server accepts players if asked to
+	if req.Readiness != nil && req.Readiness.Wait {
+		if err := orchestrator.WaitReady(ctx, d, resp.ID, *req.Readiness); err != nil {
+			d.client.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+			return nil, err
+		}
 	}
 
 	// Get and return container as server
@@ -270,41 +390,35 @@ func (d *DockerProvider) Allocate(ctx context.Context, req orchestrator.Allocate
 
 // Restart - stops and restarts a container (works whether running or stopped).
 func (d *DockerProvider) Restart(ctx context.Context, id string) error {
-	return d.client.ContainerRestart(ctx, id, container.StopOptions{})
+	return d.RestartWithOptions(ctx, id, orchestrator.StopOptions{})
 }
 
-// Exec runs a command inside a container and returns stdout.
-func (d *DockerProvider) Exec(ctx context.Context, id string, cmd []string) (string, error) {
-	execID, err := d.client.ContainerExecCreate(ctx, id, container.ExecOptions{
-		Cmd:          cmd,
-		AttachStdout: true,
-		AttachStderr: true,
-	})
-	if err != nil {
-		return "", fmt.Errorf("exec create failed: %w", err)
-	}
-
-	resp, err := d.client.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{})
-	if err != nil {
-		return "", fmt.Errorf("exec attach failed: %w", err)
+// RestartWithOptions restarts a container using the given stop
+// semantics. With a PreStopCommand the container is stopped gracefully
+// and started again; otherwise Docker's restart is used directly.
+func (d *DockerProvider) RestartWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	if opts.PreStopCommand == "" {
+		return wrapErr(d.client.ContainerRestart(ctx, id, stopOptions(opts)))
 	}
-	defer resp.Close()
 
-	var stdout, stderr bytes.Buffer
-	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
-		return "", fmt.Errorf("exec read failed: %w", err)
+	if err := d.stop(ctx, id, opts); err != nil {
+		return err
 	}
+	return wrapErr(d.client.ContainerStart(ctx, id, container.StartOptions{}))
+}
 
-	inspect, err := d.client.ContainerExecInspect(ctx, execID.ID)
+// Exec runs a command inside a container and returns stdout.
+func (d *DockerProvider) Exec(ctx context.Context, id string, cmd []string) (string, error) {
+	result, err := d.ExecWithOptions(ctx, id, cmd, orchestrator.ExecOptions{})
 	if err != nil {
-		return "", fmt.Errorf("exec inspect failed: %w", err)
+		return "", err
 	}
 
-	if inspect.ExitCode != 0 {
-		return "", fmt.Errorf("exec exit code %d: %s", inspect.ExitCode, stderr.String())
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("exec exit code %d: %s", result.ExitCode, result.Stderr)
 	}
 
-	return stdout.String(), nil
+	return result.Stdout, nil
 }
 
 // Logs returns the last `tail` lines of stdout+stderr from a container.
@@ -316,7 +430,7 @@ func (d *DockerProvider) Logs(ctx context.Context, id string, tail int) (string,
 	}
 	reader, err := d.client.ContainerLogs(ctx, id, opts)
 	if err != nil {
-		return "", fmt.Errorf("container logs failed: %w", err)
+		return "", fmt.Errorf("container logs failed: %w", wrapErr(err))
 	}
 	defer reader.Close()
 
@@ -330,16 +444,23 @@ func (d *DockerProvider) Logs(ctx context.Context, id string, tail int) (string,
 
 // Deallocate - takes id, returns error
 func (d *DockerProvider) Deallocate(ctx context.Context, id string) error {
+	return d.DeallocateWithOptions(ctx, id, orchestrator.StopOptions{})
+}
+
+// DeallocateWithOptions stops a container gracefully and removes it.
+func (d *DockerProvider) DeallocateWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
 	// Stop container
-	err := d.client.ContainerStop(ctx, id, container.StopOptions{})
+	err := d.stop(ctx, id, opts)
 	if err != nil {
 		return err
 	}
 
 	// Remove container
-	err = d.client.ContainerRemove(ctx, id, container.RemoveOptions{})
+	err = d.client.ContainerRemove(ctx, id, container.RemoveOptions{
+		RemoveVolumes: opts.RemoveVolumes,
+	})
 	if err != nil {
-		return err
+		return wrapErr(err)
 	}
 
 	return nil
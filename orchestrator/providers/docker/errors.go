@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/client"
+)
+
+// wrapErr maps a daemon error onto the orchestrator error taxonomy so
+// callers can use errors.Is. The daemon's message is kept in the chain;
+// errors that fit no category are returned unchanged.
+func wrapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isUnavailable(err):
+		return tagErr(orchestrator.ErrUnavailable, err)
+	case cerrdefs.IsNotFound(err):
+		return tagErr(orchestrator.ErrNotFound, err)
+	case cerrdefs.IsAlreadyExists(err):
+		return tagErr(orchestrator.ErrAlreadyExists, err)
+	case cerrdefs.IsResourceExhausted(err):
+		return tagErr(orchestrator.ErrResourceExhausted, err)
+	}
+	return err
+}
+
+// wrapConflict is wrapErr for calls where the daemon's 409 Conflict has
+// a single meaning: the name is taken on create, the container is
+// stopped on exec and attach.
+func wrapConflict(err error, sentinel error) error {
+	if cerrdefs.IsConflict(err) {
+		return tagErr(sentinel, err)
+	}
+	return wrapErr(err)
+}
+
+// wrapImageErr is wrapErr for calls that fail when an image can't be
+// found locally or pulled. Only an unreachable daemon is reported as
+// anything other than ErrImageMissing.
+func wrapImageErr(err error) error {
+	if err == nil || isUnavailable(err) {
+		return wrapErr(err)
+	}
+	return tagErr(orchestrator.ErrImageMissing, err)
+}
+
+// isImageNotFound reports whether ContainerCreate failed because the
+// image isn't present. The daemon uses the same 404 for a missing
+// network, so the message is the only way to tell them apart.
+func isImageNotFound(err error) bool {
+	return cerrdefs.IsNotFound(err) && strings.Contains(strings.ToLower(err.Error()), "no such image")
+}
+
+func isUnavailable(err error) bool {
+	return client.IsErrConnectionFailed(err) || cerrdefs.IsUnavailable(err)
+}
+
+func tagErr(sentinel, err error) error {
+	if errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
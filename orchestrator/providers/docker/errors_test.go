@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	cerrdefs "github.com/containerd/errdefs"
+)
+
+func TestWrapErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"not found", fmt.Errorf("No such container: abc: %w", cerrdefs.ErrNotFound), orchestrator.ErrNotFound},
+		{"already exists", cerrdefs.ErrAlreadyExists, orchestrator.ErrAlreadyExists},
+		{"exhausted", cerrdefs.ErrResourceExhausted, orchestrator.ErrResourceExhausted},
+		{"unavailable", cerrdefs.ErrUnavailable, orchestrator.ErrUnavailable},
+	}
+	for _, c := range cases {
+		got := wrapErr(c.err)
+		if !errors.Is(got, c.want) {
+			t.Errorf("%s: wrapErr = %v, want %v", c.name, got, c.want)
+		}
+		if !errors.Is(got, c.err) {
+			t.Errorf("%s: daemon error dropped from chain", c.name)
+		}
+	}
+
+	plain := errors.New("boom")
+	if got := wrapErr(plain); got != plain {
+		t.Errorf("uncategorised error should pass through, got %v", got)
+	}
+}
+
+func TestWrapConflict(t *testing.T) {
+	err := fmt.Errorf("container abc is not running: %w", cerrdefs.ErrConflict)
+	if got := wrapConflict(err, orchestrator.ErrNotRunning); !errors.Is(got, orchestrator.ErrNotRunning) {
+		t.Errorf("wrapConflict = %v, want ErrNotRunning", got)
+	}
+	if got := wrapConflict(cerrdefs.ErrNotFound, orchestrator.ErrNotRunning); !errors.Is(got, orchestrator.ErrNotFound) {
+		t.Errorf("non-conflict should fall back to wrapErr, got %v", got)
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	missing := fmt.Errorf("No such image: localhost/lobby:latest: %w", cerrdefs.ErrNotFound)
+	if !isImageNotFound(missing) {
+		t.Error("isImageNotFound should match a missing image")
+	}
+	if isImageNotFound(fmt.Errorf("network banananet not found: %w", cerrdefs.ErrNotFound)) {
+		t.Error("isImageNotFound should not match a missing network")
+	}
+	if got := wrapImageErr(missing); !errors.Is(got, orchestrator.ErrImageMissing) {
+		t.Errorf("wrapImageErr = %v, want ErrImageMissing", got)
+	}
+	if got := wrapImageErr(cerrdefs.ErrUnavailable); !errors.Is(got, orchestrator.ErrUnavailable) {
+		t.Errorf("unreachable daemon = %v, want ErrUnavailable", got)
+	}
+}
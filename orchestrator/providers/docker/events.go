@@ -2,21 +2,32 @@ package docker
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bananalabs-oss/potassium/orchestrator"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 )
 
-// ContainerEvent represents a Docker container lifecycle event.
-type ContainerEvent struct {
-	ContainerID string `json:"container_id"`
-	Name        string `json:"name"`
-	Action      string `json:"action"` // start, stop, die, restart, destroy
-	Time        int64  `json:"time"`
-}
+// ContainerEvent is kept as an alias so existing callers compile; the
+// type lives in orchestrator so every provider can emit it.
+type ContainerEvent = orchestrator.ContainerEvent
+
+// Backoff between reconnection attempts after the event stream drops.
+const (
+	eventRetryMin = 500 * time.Millisecond
+	eventRetryMax = 30 * time.Second
+)
 
-// Events subscribes to Docker container lifecycle events and returns a channel.
-// The channel closes when the context is cancelled or an error occurs.
+// Events subscribes to Docker container lifecycle events and returns a
+// channel. If the daemon connection drops, the error is reported on the
+// error channel (without blocking) and the subscription is re-opened
+// from the last event delivered, so no transition is lost or repeated.
+// Both channels close when the context is cancelled.
 func (d *DockerProvider) Events(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
 	eventCh := make(chan ContainerEvent, 32)
 	errCh := make(chan error, 1)
@@ -25,41 +36,181 @@ func (d *DockerProvider) Events(ctx context.Context) (<-chan ContainerEvent, <-c
 		defer close(eventCh)
 		defer close(errCh)
 
-		msgs, errs := d.client.Events(ctx, events.ListOptions{
-			Filters: filters.NewArgs(
-				filters.Arg("type", "container"),
-				filters.Arg("event", "start"),
-				filters.Arg("event", "stop"),
-				filters.Arg("event", "die"),
-				filters.Arg("event", "restart"),
-				filters.Arg("event", "destroy"),
-			),
-		})
-
-		for {
+		followEvents(ctx, func(ctx context.Context, since string) (<-chan events.Message, <-chan error) {
+			return d.client.Events(ctx, events.ListOptions{
+				Since: since,
+				Filters: filters.NewArgs(
+					filters.Arg("type", "container"),
+					filters.Arg("event", "create"),
+					filters.Arg("event", "start"),
+					filters.Arg("event", "stop"),
+					filters.Arg("event", "die"),
+					filters.Arg("event", "oom"),
+					filters.Arg("event", "restart"),
+					filters.Arg("event", "pause"),
+					filters.Arg("event", "unpause"),
+					filters.Arg("event", "health_status"),
+					filters.Arg("event", "destroy"),
+				),
+			})
+		}, eventCh, errCh)
+	}()
+
+	return eventCh, errCh
+}
+
+// eventSubscriber opens a daemon event stream starting at since (empty
+// for "now").
+type eventSubscriber func(ctx context.Context, since string) (<-chan events.Message, <-chan error)
+
+// followEvents keeps a subscription open until ctx is cancelled,
+// reconnecting with backoff whenever it fails.
+func followEvents(ctx context.Context, subscribe eventSubscriber, out chan<- ContainerEvent, errs chan<- error) {
+	tracker := newEventTracker()
+	retry := eventRetryMin
+
+	for {
+		subCtx, cancel := context.WithCancel(ctx)
+		since := tracker.since()
+		tracker.subscribed(time.Now())
+		msgs, subErrs := subscribe(subCtx, since)
+		received, err := tracker.pump(ctx, msgs, subErrs, out)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case errs <- wrapErr(err):
+		default:
+		}
+
+		// A subscription that delivered events was healthy; start over
+		if received {
+			retry = eventRetryMin
+		}
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			return
+		}
+		retry = min(retry*2, eventRetryMax)
+	}
+}
+
+// eventTracker converts daemon messages into ContainerEvents and
+// remembers enough to resume a stream exactly where it left off.
+type eventTracker struct {
+	firstSub int64           // when the first subscription opened
+	lastNano int64           // timestamp of the newest event delivered
+	atLast   map[string]bool // events delivered at lastNano, for dedupe
+	oom      map[string]bool // containers with an oom awaiting their die
+}
+
+func newEventTracker() *eventTracker {
+	return &eventTracker{
+		atLast: make(map[string]bool),
+		oom:    make(map[string]bool),
+	}
+}
+
+// subscribed records when the first subscription opened, so a stream
+// that drops before delivering anything still resumes from there. It
+// is kept apart from lastNano since the daemon's clock may lag ours and
+// accept would then drop its first events as replays.
+func (t *eventTracker) subscribed(at time.Time) {
+	if t.firstSub == 0 {
+		t.firstSub = at.UnixNano()
+	}
+}
+
+// since formats the resume point for the daemon's since filter: the
+// newest event delivered, or the first subscription if there is none.
+// The daemon includes events at exactly that time; accept drops the
+// ones already delivered. The first subscription starts at "now".
+func (t *eventTracker) since() string {
+	nano := t.lastNano
+	if nano == 0 {
+		nano = t.firstSub
+	}
+	if nano == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%09d", nano/int64(time.Second), nano%int64(time.Second))
+}
+
+// pump forwards events until the subscription fails or ctx is
+// cancelled. received reports whether any message arrived.
+func (t *eventTracker) pump(ctx context.Context, msgs <-chan events.Message, errs <-chan error, out chan<- ContainerEvent) (received bool, err error) {
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return received, errors.New("event stream closed")
+			}
+			received = true
+			event, ok := t.accept(msg)
+			if !ok {
+				continue
+			}
 			select {
-			case msg, ok := <-msgs:
-				if !ok {
-					return
-				}
-				name := msg.Actor.Attributes["name"]
-				eventCh <- ContainerEvent{
-					ContainerID: msg.Actor.ID,
-					Name:        name,
-					Action:      string(msg.Action),
-					Time:        msg.Time,
-				}
-			case err, ok := <-errs:
-				if !ok {
-					return
-				}
-				errCh <- err
-				return
+			case out <- event:
 			case <-ctx.Done():
-				return
+				return received, nil
 			}
+		case err, ok := <-errs:
+			if !ok || err == nil {
+				err = errors.New("event stream closed")
+			}
+			return received, err
+		case <-ctx.Done():
+			return received, nil
 		}
-	}()
+	}
+}
 
-	return eventCh, errCh
+// accept converts msg, returning false for replayed events that were
+// already delivered.
+func (t *eventTracker) accept(msg events.Message) (ContainerEvent, bool) {
+	nano := msg.TimeNano
+	if nano == 0 {
+		nano = msg.Time * int64(time.Second)
+	}
+	key := msg.Actor.ID + "\x00" + string(msg.Action)
+	switch {
+	case nano < t.lastNano:
+		return ContainerEvent{}, false
+	case nano == t.lastNano && t.atLast[key]:
+		return ContainerEvent{}, false
+	case nano > t.lastNano:
+		t.lastNano = nano
+		clear(t.atLast)
+	}
+	t.atLast[key] = true
+
+	id := msg.Actor.ID
+	event := ContainerEvent{
+		ContainerID: id,
+		Name:        msg.Actor.Attributes["name"],
+		Action:      string(msg.Action),
+		Time:        msg.Time,
+		TimeNano:    nano,
+	}
+
+	switch {
+	case strings.HasPrefix(event.Action, orchestrator.EventHealthStatus):
+		// Docker reports "health_status: healthy"
+		event.Health = strings.TrimSpace(strings.TrimPrefix(event.Action, orchestrator.EventHealthStatus+":"))
+		event.Action = orchestrator.EventHealthStatus
+	case event.Action == orchestrator.EventOOM:
+		t.oom[id] = true
+		event.OOMKilled = true
+	case event.Action == orchestrator.EventDie:
+		event.ExitCode, _ = strconv.Atoi(msg.Actor.Attributes["exitCode"])
+		event.OOMKilled = t.oom[id]
+		delete(t.oom, id)
+	case event.Action == orchestrator.EventDestroy:
+		delete(t.oom, id)
+	}
+	return event, true
 }
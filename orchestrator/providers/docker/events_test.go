@@ -0,0 +1,200 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/events"
+)
+
+func message(id string, action events.Action, nano int64, attrs map[string]string) events.Message {
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	attrs["name"] = "mc-" + id
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: attrs},
+		Time:     nano / int64(time.Second),
+		TimeNano: nano,
+	}
+}
+
+func TestEventTrackerAccept(t *testing.T) {
+	tr := newEventTracker()
+	base := int64(1_700_000_000) * int64(time.Second)
+
+	oom, _ := tr.accept(message("a", events.ActionOOM, base, nil))
+	if !oom.OOMKilled {
+		t.Error("oom event should set OOMKilled")
+	}
+	die, _ := tr.accept(message("a", events.ActionDie, base+1, map[string]string{"exitCode": "137"}))
+	if die.ExitCode != 137 || !die.OOMKilled {
+		t.Errorf("die = %+v, want exit 137 and OOMKilled", die)
+	}
+	health, _ := tr.accept(message("b", events.ActionHealthStatusUnhealthy, base+2, nil))
+	if health.Action != orchestrator.EventHealthStatus || health.Health != orchestrator.HealthUnhealthy {
+		t.Errorf("health = %+v", health)
+	}
+
+	// Replays from a resumed stream are dropped
+	if _, ok := tr.accept(message("a", events.ActionDie, base+1, nil)); ok {
+		t.Error("older event should be dropped")
+	}
+	if _, ok := tr.accept(message("b", events.ActionHealthStatusUnhealthy, base+2, nil)); ok {
+		t.Error("event already delivered at the resume point should be dropped")
+	}
+	if _, ok := tr.accept(message("c", events.ActionStart, base+2, nil)); !ok {
+		t.Error("new event at the resume point should be delivered")
+	}
+	if got, want := tr.since(), "1700000000.000000002"; got != want {
+		t.Errorf("since = %q, want %q", got, want)
+	}
+}
+
+func TestFollowEventsReconnects(t *testing.T) {
+	base := int64(1_700_000_000) * int64(time.Second)
+	var sinces []string
+
+	subscribe := func(ctx context.Context, since string) (<-chan events.Message, <-chan error) {
+		sinces = append(sinces, since)
+		first := len(sinces) == 1
+		msgs := make(chan events.Message)
+		errs := make(chan error, 1)
+		go func() {
+			var batch []events.Message
+			if first {
+				batch = []events.Message{
+					message("a", events.ActionStart, base, nil),
+					message("a", events.ActionDie, base+5, map[string]string{"exitCode": "1"}),
+				}
+			} else {
+				// The daemon replays the event at the resume point
+				batch = []events.Message{
+					message("a", events.ActionDie, base+5, map[string]string{"exitCode": "1"}),
+					message("a", events.ActionDestroy, base+9, nil),
+				}
+			}
+			for _, m := range batch {
+				select {
+				case msgs <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if first {
+				errs <- errors.New("connection reset")
+			}
+		}()
+		return msgs, errs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan ContainerEvent, 8)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		followEvents(ctx, subscribe, out, errs)
+		close(done)
+	}()
+
+	var actions []string
+	for len(actions) < 3 {
+		select {
+		case e := <-out:
+			actions = append(actions, e.Action)
+		case <-ctx.Done():
+			t.Fatalf("timed out, got %v", actions)
+		}
+	}
+	cancel()
+	<-done
+
+	if got := actions[0] + "," + actions[1] + "," + actions[2]; got != "start,die,destroy" {
+		t.Errorf("actions = %s, want start,die,destroy", got)
+	}
+	if len(sinces) != 2 || sinces[0] != "" || sinces[1] != "1700000000.000000005" {
+		t.Errorf("since values = %q", sinces)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("disconnect should be reported")
+		}
+	default:
+		t.Error("disconnect should be reported")
+	}
+}
+
+func TestFollowEventsDropBeforeFirstEvent(t *testing.T) {
+	start := time.Now()
+	sinces := make(chan string, 4)
+	subscribe := func(ctx context.Context, since string) (<-chan events.Message, <-chan error) {
+		sinces <- since
+		errs := make(chan error, 1)
+		errs <- errors.New("connection reset")
+		return make(chan events.Message), errs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		followEvents(ctx, subscribe, make(chan ContainerEvent), make(chan error, 1))
+		close(done)
+	}()
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case since := <-sinces:
+			got = append(got, since)
+		case <-ctx.Done():
+			t.Fatalf("timed out, since values %q", got)
+		}
+	}
+	cancel()
+	<-done
+
+	if got[0] != "" {
+		t.Errorf("first since = %q, want live", got[0])
+	}
+	// The resume point covers the outage from the first subscription on
+	var sec, nsec int64
+	if _, err := fmt.Sscanf(got[1], "%d.%d", &sec, &nsec); err != nil {
+		t.Fatalf("second since = %q: %v", got[1], err)
+	}
+	if resume := time.Unix(sec, nsec); resume.Before(start) || resume.After(time.Now()) {
+		t.Errorf("resumed from %v, want the first subscription (after %v)", resume, start)
+	}
+}
+
+func TestPumpClosedStream(t *testing.T) {
+	msgs := make(chan events.Message, 1)
+	msgs <- message("a", events.ActionStart, 1, nil)
+	close(msgs)
+	out := make(chan ContainerEvent, 1)
+
+	done := make(chan error)
+	go func() {
+		_, err := newEventTracker().pump(context.Background(), msgs, make(chan error), out)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("closed stream should be reported so the caller reconnects")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pump spun on a closed message channel")
+	}
+	if e := <-out; e.Action != "start" {
+		t.Errorf("event = %+v", e)
+	}
+}
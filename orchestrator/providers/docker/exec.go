@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ExecWithOptions runs cmd inside a container and returns its exit code
+// and output. When the timeout expires the exec is abandoned rather than
+// killed (Docker has no API for that), so long-running commands should
+// carry their own timeout, e.g. via `timeout 30 ...`.
+func (d *DockerProvider) ExecWithOptions(ctx context.Context, id string, cmd []string, opts orchestrator.ExecOptions) (*orchestrator.ExecResult, error) {
+	env := make([]string, 0, len(opts.Env))
+	for key, value := range opts.Env {
+		env = append(env, key+"="+value)
+	}
+
+	execID, err := d.client.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          cmd,
+		Env:          env,
+		WorkingDir:   opts.WorkingDir,
+		User:         opts.User,
+		Tty:          opts.TTY,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exec create failed: %w", wrapConflict(err, orchestrator.ErrNotRunning))
+	}
+
+	start := time.Now()
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	resp, err := d.client.ContainerExecAttach(runCtx, execID.ID, container.ExecAttachOptions{Tty: opts.TTY})
+	if err != nil {
+		return nil, fmt.Errorf("exec attach failed: %w", wrapConflict(err, orchestrator.ErrNotRunning))
+	}
+	defer resp.Close()
+
+	// The hijacked connection ignores the context; close it to unblock
+	// the reader when the timeout fires
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-runCtx.Done():
+			resp.Close()
+		case <-done:
+		}
+	}()
+
+	if opts.Stdin != nil {
+		if _, err := resp.Conn.Write(opts.Stdin); err != nil {
+			return nil, fmt.Errorf("exec stdin failed: %w", err)
+		}
+		if err := resp.CloseWrite(); err != nil {
+			return nil, fmt.Errorf("exec stdin failed: %w", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if opts.TTY {
+		_, err = io.Copy(&stdout, resp.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	}
+
+	result := &orchestrator.ExecResult{
+		ExitCode: -1,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	if runCtx.Err() != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, fmt.Errorf("exec timed out after %s: %w", opts.Timeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		return result, fmt.Errorf("exec read failed: %w", err)
+	}
+
+	inspect, err := d.client.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return result, fmt.Errorf("exec inspect failed: %w", wrapErr(err))
+	}
+	result.ExitCode = inspect.ExitCode
+	return result, nil
+}
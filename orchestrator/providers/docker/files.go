@@ -2,13 +2,20 @@ package docker
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bananalabs-oss/potassium/orchestrator"
 	"github.com/docker/docker/api/types/container"
 )
 
@@ -43,7 +50,7 @@ func (d *DockerProvider) CopyTo(ctx context.Context, id, filePath string, data [
 		return fmt.Errorf("tar close: %w", err)
 	}
 
-	return d.client.CopyToContainer(ctx, id, dir, &buf, container.CopyToContainerOptions{})
+	return wrapErr(d.client.CopyToContainer(ctx, id, dir, &buf, container.CopyToContainerOptions{}))
 }
 
 // CopyFrom reads the file at filePath inside the container and
@@ -56,7 +63,7 @@ func (d *DockerProvider) CopyFrom(ctx context.Context, id, filePath string) ([]b
 	}
 	rc, stat, err := d.client.CopyFromContainer(ctx, id, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("copy from container: %w", err)
+		return nil, fmt.Errorf("copy from container: %w", wrapErr(err))
 	}
 	defer rc.Close()
 	if stat.Mode.IsDir() {
@@ -86,6 +93,411 @@ func (d *DockerProvider) DeleteFile(ctx context.Context, id, filePath string) er
 	if !strings.HasPrefix(filePath, "/") {
 		return fmt.Errorf("filePath must be absolute, got %q", filePath)
 	}
-	_, err := d.Exec(ctx, id, []string{"rm", "-f", filePath})
+	_, err := d.fileCmd(ctx, id, "rm", "-f", filePath)
 	return err
 }
+
+// FileInfo describes a file or directory inside a container.
+type FileInfo struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	Size       int64       `json:"size"`
+	Mode       os.FileMode `json:"mode"`
+	ModTime    time.Time   `json:"mod_time"`
+	IsDir      bool        `json:"is_dir"`
+	LinkTarget string      `json:"link_target,omitempty"` // set by Stat only
+}
+
+// Stat describes the file at filePath. Works on stopped containers.
+func (d *DockerProvider) Stat(ctx context.Context, id, filePath string) (*FileInfo, error) {
+	filePath, err := containerPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	st, err := d.client.ContainerStatPath(ctx, id, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", filePath, wrapErr(err))
+	}
+	return &FileInfo{
+		Name:       st.Name,
+		Path:       filePath,
+		Size:       st.Size,
+		Mode:       st.Mode,
+		ModTime:    st.Mtime,
+		IsDir:      st.Mode.IsDir(),
+		LinkTarget: st.LinkTarget,
+	}, nil
+}
+
+// ListDir returns the entries of dir (not recursive), sorted by name.
+// Implemented via exec, so the container must be running and have
+// `find` and `stat` on PATH (coreutils and busybox both qualify).
+// Symlinks are reported as links, not followed.
+func (d *DockerProvider) ListDir(ctx context.Context, id, dir string) ([]FileInfo, error) {
+	dir, err := containerPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := d.fileCmd(ctx, id, "find", dir, "-mindepth", "1", "-maxdepth", "1",
+		"-exec", "stat", "-c", statFormat, "{}", "+")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []FileInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		info, err := parseStatLine(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// Mkdir creates dir and any missing parents. Existing directories are
+// left alone.
+func (d *DockerProvider) Mkdir(ctx context.Context, id, dir string) error {
+	dir, err := containerPath(dir)
+	if err != nil {
+		return err
+	}
+	_, err = d.fileCmd(ctx, id, "mkdir", "-p", dir)
+	return err
+}
+
+// Rename moves from to to. If to is an existing directory, from is
+// moved into it.
+func (d *DockerProvider) Rename(ctx context.Context, id, from, to string) error {
+	from, err := containerPath(from)
+	if err != nil {
+		return err
+	}
+	if to, err = containerPath(to); err != nil {
+		return err
+	}
+	if from == "/" {
+		return errors.New("refusing to move /")
+	}
+	_, err = d.fileCmd(ctx, id, "mv", from, to)
+	return err
+}
+
+// RemoveAll deletes filePath and everything under it. Removing a
+// missing path succeeds.
+func (d *DockerProvider) RemoveAll(ctx context.Context, id, filePath string) error {
+	filePath, err := containerPath(filePath)
+	if err != nil {
+		return err
+	}
+	if filePath == "/" {
+		return errors.New("refusing to remove /")
+	}
+	_, err = d.fileCmd(ctx, id, "rm", "-rf", filePath)
+	return err
+}
+
+// clearScript empties each directory argument and removes each other
+// path. Directories are kept since they may be volume mount points.
+const clearScript = `for p; do
+	if [ -d "$p" ] && [ ! -L "$p" ]; then
+		find "$p" -mindepth 1 -maxdepth 1 -exec rm -rf -- {} + || exit 1
+	else
+		rm -f -- "$p" || exit 1
+	fi
+done`
+
+// ClearPaths empties the given directories and deletes the given files.
+// Unlike RemoveAll it works on stopped containers: the deletion runs as
+// root in a short-lived container from the server's image that shares
+// its volumes, so every path must be on one of them (see Mounts). The
+// image needs sh, find and rm.
+func (d *DockerProvider) ClearPaths(ctx context.Context, id string, paths []string) error {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return wrapErr(err)
+	}
+	cmd := []string{"sh", "-c", clearScript, "sh"}
+	for _, p := range paths {
+		p, err := containerPath(p)
+		if err != nil {
+			return err
+		}
+		if !onMount(info.Mounts, p) {
+			return fmt.Errorf("%s is not on a volume of server %s", p, id)
+		}
+		cmd = append(cmd, p)
+	}
+
+	resp, err := d.client.ContainerCreate(ctx,
+		&container.Config{Image: info.Image, User: "0:0", Entrypoint: cmd},
+		&container.HostConfig{VolumesFrom: []string{id}, NetworkMode: "none"},
+		nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("create clear container: %w", wrapErr(err))
+	}
+	defer d.client.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+
+	respCh, errCh := d.client.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := d.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("start clear container: %w", wrapErr(err))
+	}
+	select {
+	case res := <-respCh:
+		if res.StatusCode != 0 {
+			return fmt.Errorf("clear paths: exit code %d", res.StatusCode)
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("clear paths: %w", wrapErr(err))
+	}
+}
+
+// onMount reports whether p is a mount destination or inside one.
+func onMount(mounts []container.MountPoint, p string) bool {
+	for _, m := range mounts {
+		if p == m.Destination || strings.HasPrefix(p, strings.TrimSuffix(m.Destination, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Mounts returns the container paths where the server's volumes and
+// bind mounts are attached, e.g. ["/data"]. Works on stopped containers.
+func (d *DockerProvider) Mounts(ctx context.Context, id string) ([]string, error) {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	paths := make([]string, 0, len(info.Mounts))
+	for _, m := range info.Mounts {
+		paths = append(paths, m.Destination)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Download streams srcPath (a file or a whole directory) to w as a tar
+// or zip archive. Entries are named relative to srcPath's parent, so
+// downloading /data/world yields world/level.dat etc. If the contents
+// exceed the size limit the transfer stops with ErrTransferTooLarge and
+// w holds a truncated archive.
+func (d *DockerProvider) Download(ctx context.Context, id, srcPath string, w io.Writer, opts TransferOptions) error {
+	srcPath, err := containerPath(srcPath)
+	if err != nil {
+		return err
+	}
+	rc, _, err := d.client.CopyFromContainer(ctx, id, srcPath)
+	if err != nil {
+		return fmt.Errorf("copy from container: %w", wrapErr(err))
+	}
+	defer rc.Close()
+
+	switch opts.Format {
+	case ArchiveTar, "":
+		return limitTar(w, rc, opts.limit())
+	case ArchiveZip:
+		return tarToZip(w, rc, opts.limit())
+	default:
+		return fmt.Errorf("unknown archive format %q", opts.Format)
+	}
+}
+
+// Upload extracts a tar or zip archive into dstDir, which must already
+// exist (see Mkdir). Every entry is checked before it reaches the
+// daemon: absolute paths, ".." components, symlinks pointing outside
+// dstDir and device files fail the upload, as does content beyond the
+// size limit. A zip is spooled to a temporary file first since it can
+// only be read with random access.
+func (d *DockerProvider) Upload(ctx context.Context, id, dstDir string, r io.Reader, opts TransferOptions) error {
+	dstDir, err := containerPath(dstDir)
+	if err != nil {
+		return err
+	}
+
+	var convert func(io.Writer) error
+	switch opts.Format {
+	case ArchiveTar, "":
+		convert = func(w io.Writer) error { return sanitizeTar(w, r, opts.limit()) }
+	case ArchiveZip:
+		zr, cleanup, err := spoolZip(r, opts.limit())
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		convert = func(w io.Writer) error { return zipToTar(w, zr, opts.limit()) }
+	default:
+		return fmt.Errorf("unknown archive format %q", opts.Format)
+	}
+
+	return d.streamToContainer(ctx, id, dstDir, convert)
+}
+
+// UploadTrusted extracts a tar archive into dstDir with every header
+// kept as is: ownership, permission bits, absolute symlinks, devices
+// and fifos all reach the daemon unchanged. Only the size limit is
+// enforced, so it is for archives this process wrote itself, such as
+// backups; use Upload for anything a user supplied.
+func (d *DockerProvider) UploadTrusted(ctx context.Context, id, dstDir string, r io.Reader, opts TransferOptions) error {
+	dstDir, err := containerPath(dstDir)
+	if err != nil {
+		return err
+	}
+	if opts.Format != ArchiveTar && opts.Format != "" {
+		return fmt.Errorf("trusted uploads must be tar, got %q", opts.Format)
+	}
+	return d.streamToContainer(ctx, id, dstDir, func(w io.Writer) error {
+		return limitTar(w, r, opts.limit())
+	})
+}
+
+// streamToContainer extracts the tar stream convert writes into dstDir,
+// without buffering it.
+func (d *DockerProvider) streamToContainer(ctx context.Context, id, dstDir string, convert func(io.Writer) error) error {
+	pr, pw := io.Pipe()
+	convertErr := make(chan error, 1)
+	go func() {
+		err := convert(pw)
+		pw.CloseWithError(err)
+		convertErr <- err
+	}()
+	err := d.client.CopyToContainer(ctx, id, dstDir, pr, container.CopyToContainerOptions{})
+	// Unblocks the converter if the daemon stopped reading early
+	pr.CloseWithError(err)
+
+	// A rejected archive is the root cause even though the daemon also
+	// fails on the truncated stream
+	if cerr := <-convertErr; cerr != nil && (err == nil || !errors.Is(cerr, err)) {
+		return cerr
+	}
+	if err != nil {
+		return fmt.Errorf("copy to container: %w", wrapErr(err))
+	}
+	return nil
+}
+
+// statFormat is the `stat -c` format parsed by parseStatLine: size, raw
+// mode in hex, mtime in epoch seconds, then the path (last, since it may
+// contain spaces).
+const statFormat = "%s %f %Y %n"
+
+func parseStatLine(line string) (FileInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return FileInfo{}, fmt.Errorf("unexpected stat output %q", line)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("unexpected stat output %q: %w", line, err)
+	}
+	raw, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("unexpected stat output %q: %w", line, err)
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("unexpected stat output %q: %w", line, err)
+	}
+
+	mode := unixMode(uint32(raw))
+	return FileInfo{
+		Name:    path.Base(fields[3]),
+		Path:    fields[3],
+		Size:    size,
+		Mode:    mode,
+		ModTime: time.Unix(mtime, 0),
+		IsDir:   mode.IsDir(),
+	}, nil
+}
+
+// unixMode converts a raw st_mode into an os.FileMode.
+func unixMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0o777)
+	switch raw & modeTypeMask {
+	case 0o040000:
+		mode |= os.ModeDir
+	case 0o120000:
+		mode |= os.ModeSymlink
+	case 0o010000:
+		mode |= os.ModeNamedPipe
+	case 0o140000:
+		mode |= os.ModeSocket
+	case 0o020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0o060000:
+		mode |= os.ModeDevice
+	}
+	if raw&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// modeTypeMask is S_IFMT, the file type bits of st_mode. Spelled out so
+// the parser builds on every GOOS.
+const modeTypeMask = 0o170000
+
+// containerPath checks and cleans a path inside the container.
+func containerPath(p string) (string, error) {
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path must be absolute, got %q", p)
+	}
+	return path.Clean(p), nil
+}
+
+// fileCmd runs a file utility in the container and returns its stdout,
+// turning a non-zero exit into an error carrying stderr.
+func (d *DockerProvider) fileCmd(ctx context.Context, id string, cmd ...string) (string, error) {
+	result, err := d.ExecWithOptions(ctx, id, cmd, orchestrator.ExecOptions{})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		msg := strings.TrimSpace(result.Stderr)
+		if strings.Contains(msg, "No such file or directory") {
+			return "", fmt.Errorf("%s: %w: %s", cmd[0], orchestrator.ErrNotFound, msg)
+		}
+		return "", fmt.Errorf("%s: exit code %d: %s", cmd[0], result.ExitCode, msg)
+	}
+	return result.Stdout, nil
+}
+
+// spoolZip copies an uploaded zip to a temporary file so it can be read
+// with random access. At most limit bytes are accepted.
+func spoolZip(r io.Reader, limit int64) (*zip.Reader, func(), error) {
+	f, err := os.CreateTemp("", "potassium-upload-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("spool upload: %w", err)
+	}
+	if n > limit {
+		cleanup()
+		return nil, nil, ErrTransferTooLarge
+	}
+	zr, err := zip.NewReader(f, n)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("read zip: %w", err)
+	}
+	return zr, cleanup, nil
+}
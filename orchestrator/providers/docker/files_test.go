@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+func TestParseStatLine(t *testing.T) {
+	info, err := parseStatLine("4096 41ed 1700000000 /data/my world")
+	if err != nil {
+		t.Fatalf("parseStatLine: %v", err)
+	}
+	if info.Name != "my world" || info.Path != "/data/my world" {
+		t.Errorf("name/path = %q %q", info.Name, info.Path)
+	}
+	if !info.IsDir || info.Mode != os.ModeDir|0o755 {
+		t.Errorf("mode = %v", info.Mode)
+	}
+	if info.Size != 4096 || !info.ModTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("size/mtime = %d %v", info.Size, info.ModTime)
+	}
+
+	link, err := parseStatLine("9 a1ff 1700000000 /data/latest")
+	if err != nil {
+		t.Fatalf("parseStatLine: %v", err)
+	}
+	if link.Mode&os.ModeSymlink == 0 || link.IsDir {
+		t.Errorf("symlink mode = %v", link.Mode)
+	}
+
+	if _, err := parseStatLine("garbage"); err == nil {
+		t.Error("parseStatLine should reject malformed lines")
+	}
+}
+
+// archiveDaemon serves one container's inspect and archive endpoints
+// plus what ClearPaths needs to run its helper container.
+type archiveDaemon struct {
+	mu       sync.Mutex
+	mounts   []container.MountPoint
+	uploaded []*tar.Header
+	created  *container.CreateRequest
+	exitCode int64
+	removed  bool
+}
+
+func (f *archiveDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case path == "/containers/server/json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Image: "sha256:game"},
+			Mounts:            f.mounts,
+			Config:            &container.Config{},
+		})
+	case path == "/containers/server/archive" && r.Method == http.MethodPut:
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			f.uploaded = append(f.uploaded, hdr)
+		}
+	case path == "/containers/create":
+		f.created = new(container.CreateRequest)
+		json.NewDecoder(r.Body).Decode(f.created)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(container.CreateResponse{ID: "helper"})
+	case path == "/containers/helper/wait":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(container.WaitResponse{StatusCode: f.exitCode})
+	case path == "/containers/helper/start":
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/containers/helper" && r.Method == http.MethodDelete:
+		f.removed = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newArchiveProvider(t *testing.T, f *archiveDaemon) *DockerProvider {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://" + srv.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DockerProvider{client: cli}
+}
+
+func TestUploadTrustedKeepsHeaders(t *testing.T) {
+	f := &archiveDaemon{}
+	d := newArchiveProvider(t, f)
+
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	tw.WriteHeader(&tar.Header{Name: "data/start.sh", Typeflag: tar.TypeReg, Mode: 0o4755, Uid: 1000, Gid: 1000, Size: 2})
+	io.WriteString(tw, "#!")
+	tw.WriteHeader(&tar.Header{Name: "data/logs", Typeflag: tar.TypeSymlink, Linkname: "/var/log/game", Uid: 1000, Gid: 1000})
+	tw.WriteHeader(&tar.Header{Name: "data/pipe", Typeflag: tar.TypeFifo, Mode: 0o600})
+	tw.Close()
+
+	if err := d.UploadTrusted(context.Background(), "server", "/", bytes.NewReader(src.Bytes()), TransferOptions{}); err != nil {
+		t.Fatalf("UploadTrusted: %v", err)
+	}
+	if len(f.uploaded) != 3 {
+		t.Fatalf("daemon got %d entries, want 3", len(f.uploaded))
+	}
+	if h := f.uploaded[0]; h.Uid != 1000 || h.Gid != 1000 || h.Mode != 0o4755 {
+		t.Errorf("start.sh = uid %d gid %d mode %#o, want unchanged", h.Uid, h.Gid, h.Mode)
+	}
+	if h := f.uploaded[1]; h.Linkname != "/var/log/game" {
+		t.Errorf("logs -> %q, want the absolute target kept", h.Linkname)
+	}
+
+	// The same archive is refused through the user-facing path
+	if err := d.Upload(context.Background(), "server", "/", bytes.NewReader(src.Bytes()), TransferOptions{}); err == nil {
+		t.Error("Upload accepted an absolute symlink")
+	}
+}
+
+func TestClearPaths(t *testing.T) {
+	f := &archiveDaemon{mounts: []container.MountPoint{{Destination: "/data"}, {Destination: "/plugins"}}}
+	d := newArchiveProvider(t, f)
+	ctx := context.Background()
+
+	if err := d.ClearPaths(ctx, "server", []string{"/data", "/plugins/essentials/"}); err != nil {
+		t.Fatalf("ClearPaths: %v", err)
+	}
+	c := f.created
+	if c == nil || c.Image != "sha256:game" || c.User != "0:0" {
+		t.Fatalf("helper container = %+v", c)
+	}
+	if got := strings.Join(c.HostConfig.VolumesFrom, ","); got != "server" {
+		t.Errorf("VolumesFrom = %s, want server", got)
+	}
+	if args := c.Entrypoint[len(c.Entrypoint)-2:]; args[0] != "/data" || args[1] != "/plugins/essentials" {
+		t.Errorf("cleared %v", args)
+	}
+	if !f.removed {
+		t.Error("helper container left behind")
+	}
+
+	f.created = nil
+	if err := d.ClearPaths(ctx, "server", []string{"/data", "/opt/game"}); err == nil || f.created != nil {
+		t.Errorf("ClearPaths off the volumes = %v, ran helper: %v", err, f.created != nil)
+	}
+
+	f.exitCode = 1
+	if err := d.ClearPaths(ctx, "server", []string{"/data"}); err == nil {
+		t.Error("ClearPaths ignored a failed helper")
+	}
+}
@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Health reports the container's HEALTHCHECK status: starting, healthy,
+// unhealthy, or none when the container has no health check.
+func (d *DockerProvider) Health(ctx context.Context, id string) (string, error) {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	if info.State == nil || info.State.Health == nil {
+		return orchestrator.HealthNone, nil
+	}
+	return info.State.Health.Status, nil
+}
+
+// WaitReady blocks until the container satisfies spec. See
+// orchestrator.WaitReady.
+func (d *DockerProvider) WaitReady(ctx context.Context, id string, spec orchestrator.ReadinessSpec) error {
+	return orchestrator.WaitReady(ctx, d, id, spec)
+}
+
+// healthConfig maps a HealthCheck onto Docker's HEALTHCHECK config. An
+// empty Test keeps the image's own check and only overrides timings.
+func healthConfig(hc *orchestrator.HealthCheck) *container.HealthConfig {
+	return &container.HealthConfig{
+		Test:        hc.Test,
+		Interval:    time.Duration(hc.IntervalSeconds) * time.Second,
+		Timeout:     time.Duration(hc.TimeoutSeconds) * time.Second,
+		StartPeriod: time.Duration(hc.StartPeriodSeconds) * time.Second,
+		Retries:     hc.Retries,
+	}
+}
@@ -0,0 +1,215 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// defaultRegistry is where image references without a registry host
+// (e.g. "itzg/minecraft-server") are pulled from.
+const defaultRegistry = "docker.io"
+
+// RegistryAuth holds credentials for a private registry. Set either
+// Username/Password or an IdentityToken.
+type RegistryAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identity_token,omitempty"`
+}
+
+// PullProgress is one progress update from an image pull. Layer updates
+// carry the layer ID and byte counts; overall status lines have no ID.
+type PullProgress struct {
+	ID      string `json:"id,omitempty"`
+	Status  string `json:"status"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// Image is a locally stored image.
+type Image struct {
+	ID      string   `json:"id"`
+	Tags    []string `json:"tags"`
+	Size    int64    `json:"size"`
+	Created int64    `json:"created"`
+}
+
+// WithRegistryAuth registers credentials used when pulling images from
+// host (e.g. "ghcr.io"; "docker.io" for Docker Hub).
+func WithRegistryAuth(host string, auth RegistryAuth) Option {
+	return func(d *DockerProvider) {
+		d.registryAuth[normalizeRegistryHost(host)] = auth
+	}
+}
+
+// WithPullProgress installs a callback for pulls triggered by Allocate,
+// so bootstrapping nodes can report download progress.
+func WithPullProgress(fn func(image string, p PullProgress)) Option {
+	return func(d *DockerProvider) {
+		d.pullProgress = fn
+	}
+}
+
+// PullImage pulls ref, using registry credentials configured with
+// WithRegistryAuth, and reports progress to the optional callback as
+// the daemon streams it. Blocks until the pull completes.
+func (d *DockerProvider) PullImage(ctx context.Context, ref string, progress func(PullProgress)) error {
+	opts := image.PullOptions{}
+	if auth, ok := d.registryAuth[registryHost(ref)]; ok {
+		encoded, err := registry.EncodeAuthConfig(registry.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+		})
+		if err != nil {
+			return fmt.Errorf("encode registry auth: %w", err)
+		}
+		opts.RegistryAuth = encoded
+	}
+
+	reader, err := d.client.ImagePull(ctx, ref, opts)
+	if err != nil {
+		return fmt.Errorf("pull %s: %w", ref, wrapImageErr(err))
+	}
+	defer reader.Close()
+
+	// The daemon reports failures mid-stream, not via the HTTP status
+	dec := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("pull %s: %w", ref, wrapErr(err))
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("pull %s: %w", ref, pullStreamErr(msg.Error.Message))
+		}
+		if progress != nil {
+			p := PullProgress{ID: msg.ID, Status: msg.Status}
+			if msg.Progress != nil {
+				p.Current = msg.Progress.Current
+				p.Total = msg.Progress.Total
+			}
+			progress(p)
+		}
+	}
+}
+
+// pullStreamErr turns an error reported in the pull stream into an
+// error. Only the registry's answers for a missing tag or repository
+// ("manifest unknown", "manifest for ... not found", "pull access
+// denied") are ErrImageMissing; layer, disk and network failures
+// mid-pull are returned as the daemon put them.
+func pullStreamErr(message string) error {
+	lower := strings.ToLower(message)
+	missing := strings.Contains(lower, "manifest unknown") ||
+		strings.HasPrefix(lower, "pull access denied") ||
+		(strings.HasPrefix(lower, "manifest for ") && strings.Contains(lower, " not found"))
+	if missing {
+		return fmt.Errorf("%w: %s", orchestrator.ErrImageMissing, message)
+	}
+	return errors.New(message)
+}
+
+// ListImages returns the images stored on the daemon.
+func (d *DockerProvider) ListImages(ctx context.Context) ([]Image, error) {
+	summaries, err := d.client.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	images := make([]Image, 0, len(summaries))
+	for _, s := range summaries {
+		images = append(images, Image{
+			ID:      s.ID,
+			Tags:    s.RepoTags,
+			Size:    s.Size,
+			Created: s.Created,
+		})
+	}
+	return images, nil
+}
+
+// RemoveImage deletes an image by reference or ID. force also removes
+// it when stopped containers still reference it.
+func (d *DockerProvider) RemoveImage(ctx context.Context, ref string, force bool) error {
+	_, err := d.client.ImageRemove(ctx, ref, image.RemoveOptions{
+		Force:         force,
+		PruneChildren: true,
+	})
+	return wrapErr(err)
+}
+
+// PruneImages removes dangling images, or every image not used by a
+// container when all is set. Returns the bytes reclaimed.
+func (d *DockerProvider) PruneImages(ctx context.Context, all bool) (uint64, error) {
+	args := filters.NewArgs()
+	if all {
+		args.Add("dangling", "false")
+	}
+	report, err := d.client.ImagesPrune(ctx, args)
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	return report.SpaceReclaimed, nil
+}
+
+// ensureImage applies policy before a container is created from ref.
+func (d *DockerProvider) ensureImage(ctx context.Context, ref string, policy orchestrator.PullPolicy) error {
+	var progress func(PullProgress)
+	if d.pullProgress != nil {
+		progress = func(p PullProgress) { d.pullProgress(ref, p) }
+	}
+
+	switch policy {
+	case orchestrator.PullNever:
+		return nil
+	case orchestrator.PullAlways:
+		return d.PullImage(ctx, ref, progress)
+	case orchestrator.PullIfNotPresent, "":
+		_, err := d.client.ImageInspect(ctx, ref)
+		if err == nil {
+			return nil
+		}
+		if !cerrdefs.IsNotFound(err) {
+			return wrapErr(err)
+		}
+		return d.PullImage(ctx, ref, progress)
+	default:
+		return fmt.Errorf("unknown pull policy %q", policy)
+	}
+}
+
+// registryHost extracts the registry host from an image reference. The
+// first path component is a host only if it looks like one (has a dot or
+// port, or is localhost); otherwise the image lives on Docker Hub.
+func registryHost(ref string) string {
+	first, _, found := strings.Cut(ref, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return normalizeRegistryHost(first)
+	}
+	return defaultRegistry
+}
+
+func normalizeRegistryHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return defaultRegistry
+	}
+	return host
+}
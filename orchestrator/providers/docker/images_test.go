@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func TestRegistryHost(t *testing.T) {
+	cases := map[string]string{
+		"itzg/minecraft-server":           "docker.io",
+		"alpine:3.20":                     "docker.io",
+		"ghcr.io/bananalabs/lobby:1.2":    "ghcr.io",
+		"localhost/hytale-server":         "localhost",
+		"registry.local:5000/game/server": "registry.local:5000",
+		"index.docker.io/library/alpine":  "docker.io",
+	}
+	for ref, want := range cases {
+		if got := registryHost(ref); got != want {
+			t.Errorf("registryHost(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestPullStreamErr(t *testing.T) {
+	cases := map[string]bool{
+		"manifest for lobby:9.9 not found: manifest unknown: manifest unknown":                            true,
+		"pull access denied for bananalabs/nope, repository does not exist or may require 'docker login'": true,
+		"manifest for lobby:9.9 not found":                                                                true,
+		"write /var/lib/docker/tmp/GetImageBlob: no space left on device":                                 false,
+		"failed to register layer: open /var/lib/docker/overlay2/x/diff/etc: file not found":              false,
+		"unexpected EOF": false,
+	}
+	for msg, missing := range cases {
+		err := pullStreamErr(msg)
+		if errors.Is(err, orchestrator.ErrImageMissing) != missing {
+			t.Errorf("pullStreamErr(%q) = %v, want ErrImageMissing: %v", msg, err, missing)
+		}
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("pullStreamErr(%q) lost the message: %v", msg, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// limitWatchReplay is how far back the invalidation watch starts, so an
+// update racing the subscription (or a little clock skew with the
+// daemon) still invalidates. Replays only ever evict entries.
+const limitWatchReplay = 5 * time.Second
+
+// limitCache remembers each container's resource limits so List doesn't
+// need an inspect per container. Limits only change through the update
+// API, so a daemon event watch for "update" and "destroy" keeps entries
+// fresh. Entries are only stored while the watch is running; if it
+// fails the cache is emptied and the next List starts a new one.
+type limitCache struct {
+	mu       sync.Mutex
+	entries  map[string]container.Resources
+	gen      uint64 // bumped on every invalidation
+	watching bool
+	stop     context.CancelFunc
+	closed   bool
+}
+
+func newLimitCache() *limitCache {
+	return &limitCache{entries: make(map[string]container.Resources)}
+}
+
+// get returns the cached limits for id. When missing, gen must be passed
+// to put so a result that raced an invalidation isn't stored.
+func (c *limitCache) get(id string) (resources container.Resources, gen uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resources, ok = c.entries[id]
+	return resources, c.gen, ok
+}
+
+func (c *limitCache) put(id string, resources container.Resources, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.watching && c.gen == gen {
+		c.entries[id] = resources
+	}
+}
+
+func (c *limitCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+	c.gen++
+}
+
+// reset drops every entry and marks the watch as stopped.
+func (c *limitCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.gen++
+	c.watching = false
+}
+
+// watchLimits starts the invalidation watch if it isn't running. The
+// watch outlives the List call that started it and runs until it fails
+// or the provider is closed.
+func (d *DockerProvider) watchLimits() {
+	c := d.limits
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.watching || c.closed {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.watching = true
+	c.stop = cancel
+
+	since := strconv.FormatInt(time.Now().Add(-limitWatchReplay).Unix(), 10)
+	msgs, errs := d.client.Events(ctx, events.ListOptions{
+		Since: since,
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("event", "update"),
+			filters.Arg("event", "destroy"),
+		),
+	})
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case msg := <-msgs:
+				c.invalidate(msg.Actor.ID)
+			case <-errs:
+				c.reset()
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// containerLimits returns a container's resource limits, inspecting only
+// on a cache miss.
+func (d *DockerProvider) containerLimits(ctx context.Context, id string) (container.Resources, bool) {
+	resources, gen, ok := d.limits.get(id)
+	if ok {
+		return resources, true
+	}
+	inspect, err := d.client.ContainerInspect(ctx, id)
+	if err != nil || inspect.HostConfig == nil {
+		return container.Resources{}, false
+	}
+	d.limits.put(id, inspect.HostConfig.Resources, gen)
+	return inspect.HostConfig.Resources, true
+}
+
+// Close stops background work and releases the daemon connection.
+func (d *DockerProvider) Close() error {
+	c := d.limits
+	c.mu.Lock()
+	c.closed = true
+	if c.stop != nil {
+		c.stop()
+	}
+	c.mu.Unlock()
+	c.reset()
+
+	return d.client.Close()
+}
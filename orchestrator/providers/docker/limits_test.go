@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+)
+
+// fakeDaemon serves just enough of the Engine API for List: the
+// container list, inspect and a held-open event stream.
+type fakeDaemon struct {
+	containers []container.Summary
+	memory     map[string]int64
+	inspects   atomic.Int64
+	events     chan events.Message
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+func newFakeDaemon(n int) *fakeDaemon {
+	f := &fakeDaemon{
+		memory: make(map[string]int64),
+		events: make(chan events.Message, 16),
+	}
+	for i := range n {
+		id := fmt.Sprintf("%064x", i+1)
+		f.containers = append(f.containers, container.Summary{
+			ID:              id,
+			Names:           []string{fmt.Sprintf("/server-%d", i)},
+			State:           "running",
+			Labels:          map[string]string{orchestrator.LabelManaged: "true"},
+			NetworkSettings: &container.NetworkSettingsSummary{},
+		})
+		f.memory[id] = 1 << 30
+	}
+	return f
+}
+
+func (f *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case path == "/containers/json":
+		json.NewEncoder(w).Encode(f.containers)
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/json"):
+		f.inspects.Add(1)
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json")
+		json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				ID:    id,
+				State: &container.State{Status: "running"},
+				HostConfig: &container.HostConfig{
+					Resources: container.Resources{Memory: f.memory[id]},
+				},
+			},
+		})
+	case path == "/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case msg := <-f.events:
+				enc.Encode(msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeProvider(tb testing.TB, f *fakeDaemon) *DockerProvider {
+	tb.Helper()
+	srv := httptest.NewServer(f)
+	tb.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://" + srv.Listener.Addr().String()))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	d, err := New(WithClient(cli))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestListCachesLimits(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeDaemon(3)
+	d := newFakeProvider(t, f)
+
+	servers, err := d.List(ctx, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(servers) != 3 || servers[0].MemoryLimit != 1<<30 {
+		t.Fatalf("List = %+v", servers)
+	}
+	if n := f.inspects.Load(); n != 3 {
+		t.Fatalf("first List inspected %d containers, want 3", n)
+	}
+
+	d.List(ctx, nil)
+	if n := f.inspects.Load(); n != 3 {
+		t.Errorf("second List inspected again (%d total)", n)
+	}
+
+	// An update from outside (docker update) evicts that container
+	target := f.containers[1].ID
+	f.memory[target] = 2 << 30
+	f.events <- events.Message{Type: events.ContainerEventType, Action: "update", Actor: events.Actor{ID: target}}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, _, ok := d.limits.get(target); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("update event did not invalidate the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	servers, _ = d.List(ctx, nil)
+	if n := f.inspects.Load(); n != 4 {
+		t.Errorf("inspects after update = %d, want 4", n)
+	}
+	if servers[1].MemoryLimit != 2<<30 {
+		t.Errorf("MemoryLimit after update = %d, want %d", servers[1].MemoryLimit, 2<<30)
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("uncached", func(b *testing.B) {
+		f := newFakeDaemon(200)
+		d := newFakeProvider(b, f)
+		for b.Loop() {
+			d.limits.mu.Lock()
+			clear(d.limits.entries)
+			d.limits.mu.Unlock()
+			if _, err := d.List(ctx, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(f.inspects.Load())/float64(b.N), "inspects/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		f := newFakeDaemon(200)
+		d := newFakeProvider(b, f)
+		d.List(ctx, nil)
+		f.inspects.Store(0)
+		for b.Loop() {
+			if _, err := d.List(ctx, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(f.inspects.Load())/float64(b.N), "inspects/op")
+	})
+}
@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// StreamLogs streams a container's stdout and stderr line by line. Lines
+// carry the daemon's timestamp and the stream they were written to
+// (TTY containers merge both into stdout).
+func (d *DockerProvider) StreamLogs(ctx context.Context, id string, opts orchestrator.LogOptions) (<-chan orchestrator.LogLine, <-chan error) {
+	lineCh := make(chan orchestrator.LogLine, 64)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(lineCh)
+		defer close(errCh)
+
+		info, err := d.client.ContainerInspect(ctx, id)
+		if err != nil {
+			errCh <- wrapErr(err)
+			return
+		}
+
+		// Timestamps are always requested to fill LogLine.Time; the
+		// prefix is stripped from the text by lineWriter
+		logOpts := container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Timestamps: true,
+			Follow:     opts.Follow,
+			Tail:       "all",
+		}
+		if opts.Tail > 0 {
+			logOpts.Tail = strconv.Itoa(opts.Tail)
+		}
+		if !opts.Since.IsZero() {
+			logOpts.Since = opts.Since.Format(time.RFC3339Nano)
+		}
+		if !opts.Until.IsZero() {
+			logOpts.Until = opts.Until.Format(time.RFC3339Nano)
+		}
+
+		reader, err := d.client.ContainerLogs(ctx, id, logOpts)
+		if err != nil {
+			errCh <- wrapErr(err)
+			return
+		}
+		defer reader.Close()
+
+		stdout := newLineWriter(ctx, lineCh, orchestrator.StreamStdout, true)
+		stderr := newLineWriter(ctx, lineCh, orchestrator.StreamStderr, true)
+
+		if info.Config != nil && info.Config.Tty {
+			_, err = io.Copy(stdout, reader)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, reader)
+		}
+		stdout.Flush()
+		stderr.Flush()
+
+		// Cancellation is the normal way to stop following
+		if err != nil && ctx.Err() == nil {
+			errCh <- err
+		}
+	}()
+
+	return lineCh, errCh
+}
+
+// lineWriter splits a byte stream into LogLines. Docker frames don't
+// align with lines, so partial lines are buffered until their newline
+// arrives (or Flush is called at end of stream).
+type lineWriter struct {
+	ctx        context.Context
+	out        chan<- orchestrator.LogLine
+	stream     orchestrator.LogStream
+	timestamps bool // lines are prefixed with an RFC3339Nano timestamp
+	buf        bytes.Buffer
+}
+
+func newLineWriter(ctx context.Context, out chan<- orchestrator.LogLine, stream orchestrator.LogStream, timestamps bool) *lineWriter {
+	return &lineWriter{
+		ctx:        ctx,
+		out:        out,
+		stream:     stream,
+		timestamps: timestamps,
+	}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf.Next(i + 1))
+		if err := w.emit(strings.TrimRight(line, "\r\n")); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush emits any buffered partial line.
+func (w *lineWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	return w.emit(strings.TrimRight(line, "\r\n"))
+}
+
+func (w *lineWriter) emit(text string) error {
+	line := orchestrator.LogLine{Stream: w.stream, Text: text}
+	if w.timestamps {
+		if ts, rest, ok := strings.Cut(text, " "); ok {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				line.Time = t
+				line.Text = rest
+			}
+		}
+	}
+	if line.Time.IsZero() {
+		line.Time = time.Now()
+	}
+
+	select {
+	case w.out <- line:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
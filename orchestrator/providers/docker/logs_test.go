@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func TestLineWriter_SplitsFramesIntoLines(t *testing.T) {
+	out := make(chan orchestrator.LogLine, 8)
+	w := newLineWriter(context.Background(), out, orchestrator.StreamStderr, true)
+
+	// Frames split mid-timestamp and mid-line, as Docker's may
+	w.Write([]byte("2026-01-02T03:04:05.000000006Z Server st"))
+	w.Write([]byte("arted\n2026-01-02T03:04:06Z second\r\npartial"))
+	w.Flush()
+	close(out)
+
+	var got []orchestrator.LogLine
+	for line := range out {
+		got = append(got, line)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d lines, want 3: %+v", len(got), got)
+	}
+
+	want := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	if got[0].Text != "Server started" || !got[0].Time.Equal(want) {
+		t.Errorf("line 0 = %+v", got[0])
+	}
+	if got[0].Stream != orchestrator.StreamStderr {
+		t.Errorf("Stream = %q, want stderr", got[0].Stream)
+	}
+	if got[1].Text != "second" {
+		t.Errorf("line 1 text = %q, want %q", got[1].Text, "second")
+	}
+	if got[2].Text != "partial" || got[2].Time.IsZero() {
+		t.Errorf("line 2 = %+v, want untimestamped %q stamped with now", got[2], "partial")
+	}
+}
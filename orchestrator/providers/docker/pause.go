@@ -0,0 +1,19 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Pause freezes a running container via the cgroup freezer. Pausing a
+// stopped container fails with orchestrator.ErrNotRunning.
+func (d *DockerProvider) Pause(ctx context.Context, id string) error {
+	return wrapConflict(d.client.ContainerPause(ctx, id), orchestrator.ErrNotRunning)
+}
+
+// Resume thaws a paused container. Resuming a container that isn't
+// paused fails with orchestrator.ErrNotRunning.
+func (d *DockerProvider) Resume(ctx context.Context, id string) error {
+	return wrapConflict(d.client.ContainerUnpause(ctx, id), orchestrator.ErrNotRunning)
+}
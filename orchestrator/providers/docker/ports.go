@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// usedHostPorts returns the host ports currently published by containers
+// on this daemon, keyed by hostPortKey. Stopped containers don't publish
+// ports, so their bindings are only protected by the host bind probe.
+func (d *DockerProvider) usedHostPorts(ctx context.Context) (map[string]bool, error) {
+	containers, err := d.client.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	used := map[string]bool{}
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			if p.PublicPort != 0 {
+				used[hostPortKey(p.Type, int(p.PublicPort))] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+func hostPortKey(protocol string, port int) string {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return strings.ToLower(protocol) + "/" + strconv.Itoa(port)
+}
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+)
+
+// UpdateResources changes a container's limits in place via Docker's
+// update API, so a plan upgrade doesn't need a reallocation. When
+// raising MemoryLimit on a container with a swap limit, set MemorySwap
+// too: Docker rejects a memory limit above the existing swap limit.
+func (d *DockerProvider) UpdateResources(ctx context.Context, id string, update orchestrator.ResourceUpdate) error {
+	_, err := d.client.ContainerUpdate(ctx, id, container.UpdateConfig{
+		Resources: resourceLimits(update),
+	})
+	// Don't wait for the update event to reach the List cache
+	d.limits.invalidate(id)
+	return wrapErr(err)
+}
+
+// resourceLimits maps the limits shared by Allocate and UpdateResources
+// onto Docker resources. Zero values leave the limit unset.
+func resourceLimits(limits orchestrator.ResourceUpdate) container.Resources {
+	resources := container.Resources{}
+	if limits.MemoryLimit > 0 {
+		resources.Memory = limits.MemoryLimit
+	}
+	if limits.CPULimit > 0 {
+		resources.NanoCPUs = int64(limits.CPULimit * 1e9)
+	}
+	if limits.PidsLimit > 0 {
+		pids := limits.PidsLimit
+		resources.PidsLimit = &pids
+	}
+	if limits.MemorySwap != 0 {
+		resources.MemorySwap = limits.MemorySwap
+	}
+	return resources
+}
+
+// applyLimits copies a container's effective limits onto server.
+func applyLimits(server *orchestrator.Server, resources container.Resources) {
+	server.MemoryLimit = resources.Memory
+	server.MemorySwap = resources.MemorySwap
+	if resources.NanoCPUs > 0 {
+		server.CPULimit = float64(resources.NanoCPUs) / 1e9
+	}
+	if resources.PidsLimit != nil && *resources.PidsLimit > 0 {
+		server.PidsLimit = *resources.PidsLimit
+	}
+}
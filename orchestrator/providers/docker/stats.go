@@ -7,28 +7,25 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bananalabs-oss/potassium/orchestrator"
 	"github.com/docker/docker/api/types/container"
 )
 
-// ContainerStats represents a point-in-time resource usage snapshot.
-type ContainerStats struct {
-	ContainerID  string  `json:"container_id"`
-	Name         string  `json:"name"`
-	CPUPercent   float64 `json:"cpu_percent"`
-	MemoryUsed   int64   `json:"memory_used"`
-	MemoryLimit  int64   `json:"memory_limit"`
-	NetRxBytes   int64   `json:"net_rx_bytes"`
-	NetTxBytes   int64   `json:"net_tx_bytes"`
-	DiskReadBytes  int64 `json:"disk_read_bytes"`
-	DiskWriteBytes int64 `json:"disk_write_bytes"`
-	Timestamp    int64   `json:"timestamp"`
-}
+// ContainerStats is kept as an alias so existing callers compile; the
+// type lives in orchestrator so every provider can report it.
+type ContainerStats = orchestrator.ContainerStats
+
+var _ orchestrator.StatsSource = (*DockerProvider)(nil)
 
 // Stats returns a one-shot resource usage snapshot for a single container.
 func (d *DockerProvider) Stats(ctx context.Context, id string) (*ContainerStats, error) {
+	if d.cgroups != nil {
+		return d.statsCgroup(ctx, id)
+	}
+
 	resp, err := d.client.ContainerStats(ctx, id, false)
 	if err != nil {
-		return nil, err
+		return nil, wrapErr(err)
 	}
 	defer resp.Body.Close()
 
@@ -92,11 +89,16 @@ func (d *DockerProvider) Stats(ctx context.Context, id string) (*ContainerStats,
 }
 
 // StatsAll returns resource usage snapshots for all running containers.
-// Uses bounded concurrency of 5 goroutines.
+// Uses bounded concurrency of 5 goroutines, or reads cgroups directly
+// when configured with WithCgroupStats.
 func (d *DockerProvider) StatsAll(ctx context.Context) ([]ContainerStats, error) {
+	if d.cgroups != nil {
+		return d.statsAllCgroup(ctx)
+	}
+
 	containers, err := d.client.ContainerList(ctx, container.ListOptions{})
 	if err != nil {
-		return nil, err
+		return nil, wrapErr(err)
 	}
 
 	var (
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/docker/docker/api/types/container"
+)
+
+// stop brings a container down. A PreStopCommand is sent to the console
+// first and the container given PreStopTimeoutSeconds to exit on its
+// own; if it's still running (or the command couldn't be sent) it is
+// signalled and killed after the grace period.
+func (d *DockerProvider) stop(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	if opts.PreStopCommand != "" {
+		if err := d.SendCommand(ctx, id, opts.PreStopCommand); err == nil {
+			timeout := opts.PreStopTimeoutSeconds
+			if timeout <= 0 {
+				timeout = orchestrator.DefaultPreStopTimeoutSeconds
+			}
+			if d.waitExit(ctx, id, time.Duration(timeout)*time.Second) {
+				return nil
+			}
+		}
+	}
+
+	// Stopping an already-stopped container is a no-op
+	return wrapErr(d.client.ContainerStop(ctx, id, stopOptions(opts)))
+}
+
+// waitExit reports whether the container stopped within timeout.
+func (d *DockerProvider) waitExit(ctx context.Context, id string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	respCh, errCh := d.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case <-respCh:
+		return true
+	case <-errCh:
+		return false
+	}
+}
+
+func stopOptions(opts orchestrator.StopOptions) container.StopOptions {
+	stop := container.StopOptions{Signal: opts.Signal}
+	if opts.GracePeriodSeconds > 0 {
+		timeout := opts.GracePeriodSeconds
+		stop.Timeout = &timeout
+	}
+	return stop
+}
@@ -0,0 +1,89 @@
+package docker
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func TestStopOptions(t *testing.T) {
+	for _, tc := range []struct {
+		opts    orchestrator.StopOptions
+		timeout int // -1 for the daemon default
+	}{
+		{orchestrator.StopOptions{}, -1},
+		{orchestrator.StopOptions{GracePeriodSeconds: -5}, -1},
+		{orchestrator.StopOptions{GracePeriodSeconds: 10}, 10},
+		{orchestrator.StopOptions{Signal: "SIGINT", GracePeriodSeconds: 3}, 3},
+	} {
+		got := stopOptions(tc.opts)
+		if got.Signal != tc.opts.Signal {
+			t.Errorf("stopOptions(%+v).Signal = %q", tc.opts, got.Signal)
+		}
+		switch {
+		case tc.timeout < 0 && got.Timeout != nil:
+			t.Errorf("stopOptions(%+v).Timeout = %d, want default", tc.opts, *got.Timeout)
+		case tc.timeout >= 0 && (got.Timeout == nil || *got.Timeout != tc.timeout):
+			t.Errorf("stopOptions(%+v).Timeout = %v, want %d", tc.opts, got.Timeout, tc.timeout)
+		}
+	}
+}
+
+func TestStopPreStopCommand(t *testing.T) {
+	opts := orchestrator.StopOptions{
+		Signal:                "SIGINT",
+		GracePeriodSeconds:    5,
+		PreStopCommand:        "stop",
+		PreStopTimeoutSeconds: 1,
+	}
+
+	for _, tc := range []struct {
+		name    string
+		daemon  *consoleDaemon
+		sent    bool
+		signals bool
+	}{
+		{"exits on its own", &consoleDaemon{running: true, exits: true}, true, false},
+		{"wait fails", &consoleDaemon{running: true}, true, true},
+		{"attach fails", &consoleDaemon{running: true, attachFails: true}, false, true},
+		{"not running", &consoleDaemon{}, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.daemon
+			d := newConsoleProvider(t, f)
+			if err := d.stop(context.Background(), "server", opts); err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+
+			select {
+			case got := <-f.stdin:
+				if !tc.sent || got != "stop\n" {
+					t.Errorf("console got %q", got)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tc.sent {
+					t.Error("PreStopCommand not sent")
+				}
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if !tc.signals {
+				if len(f.stops) != 0 {
+					t.Errorf("signalled a server that exited: %q", f.stops)
+				}
+				return
+			}
+			if len(f.stops) != 1 {
+				t.Fatalf("stop requests = %q, want one", f.stops)
+			}
+			q, _ := url.ParseQuery(f.stops[0])
+			if q.Get("signal") != "SIGINT" || q.Get("t") != "5" {
+				t.Errorf("stop query = %q", f.stops[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+// Package multi combines several providers, one per host, into a single
+// orchestrator.Provider. Allocate picks a node with a placement Strategy
+// and every other call is routed by the server ID, which carries the
+// node name: "<node>/<id on that node>".
+//
+//	cli, _ := client.NewClientWithOpts(client.WithHost("tcp://eu-1:2376"), client.WithTLSClientConfigFromEnv())
+//	eu1, _ := docker.New(docker.WithClient(cli))
+//	p, err := multi.New([]multi.Node{
+//		{Name: "eu-1", Provider: eu1, Labels: map[string]string{"region": "eu"}},
+//		{Name: "us-1", Provider: us1, Labels: map[string]string{"region": "us"}},
+//	}, multi.WithStrategy(multi.BinPack()))
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// LabelNode is set on every server multi returns, naming the node it
+// runs on. Passing it in a List filter only queries that node.
+const LabelNode = "potassium.node"
+
+// Node is one underlying provider.
+type Node struct {
+	// Name identifies the node in server IDs. It must be unique and must
+	// not contain "/".
+	Name     string
+	Provider orchestrator.Provider
+	// Labels describe the node for placement constraints, e.g.
+	// {"region": "eu", "disk": "nvme"}.
+	Labels map[string]string
+	// Capacity is what may be allocated on the node. Zero fields are
+	// unlimited.
+	Capacity Capacity
+}
+
+// Capacity bounds the summed limits of servers on a node.
+type Capacity struct {
+	MemoryBytes int64
+	CPUs        float64
+}
+
+// Provider routes calls to the node owning each server.
+type Provider struct {
+	nodes    []Node
+	byName   map[string]Node
+	strategy Strategy
+
+	place   sync.Mutex                // serializes choosing a node
+	pending map[string][]*reservation // node name -> Allocates in flight there, guarded by place
+}
+
+// reservation is what an Allocate in flight will add to its node, so
+// placements made meanwhile count it before the node lists the server.
+type reservation struct {
+	memory int64
+	cpus   float64
+	labels map[string]string
+}
+
+var _ orchestrator.Provider = (*Provider)(nil)
+var _ orchestrator.EventSource = (*Provider)(nil)
+var _ orchestrator.StatsSource = (*Provider)(nil)
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithStrategy sets how Allocate chooses among the nodes that satisfy a
+// request's constraints. Default Spread.
+func WithStrategy(s Strategy) Option {
+	return func(p *Provider) {
+		p.strategy = s
+	}
+}
+
+// New combines nodes, in order of preference where a strategy ties.
+func New(nodes []Node, opts ...Option) (*Provider, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("multi provider needs at least one node")
+	}
+	p := &Provider{
+		nodes:    nodes,
+		byName:   make(map[string]Node, len(nodes)),
+		strategy: Spread(),
+		pending:  make(map[string][]*reservation),
+	}
+	for _, n := range nodes {
+		switch {
+		case n.Name == "" || strings.Contains(n.Name, "/"):
+			return nil, fmt.Errorf("invalid node name %q", n.Name)
+		case n.Provider == nil:
+			return nil, fmt.Errorf("node %s has no provider", n.Name)
+		}
+		if _, dup := p.byName[n.Name]; dup {
+			return nil, fmt.Errorf("node %s listed twice", n.Name)
+		}
+		p.byName[n.Name] = n
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// ServerID joins a node name and the node's own server ID.
+func ServerID(node, id string) string {
+	return node + "/" + id
+}
+
+// SplitID returns the node and node-local ID of a multi server ID.
+func SplitID(id string) (node, local string, ok bool) {
+	return strings.Cut(id, "/")
+}
+
+// route resolves a server ID to its node.
+func (p *Provider) route(id string) (Node, string, error) {
+	name, local, ok := SplitID(id)
+	if !ok {
+		return Node{}, "", fmt.Errorf("server %s has no node prefix: %w", id, orchestrator.ErrNotFound)
+	}
+	n, ok := p.byName[name]
+	if !ok {
+		return Node{}, "", fmt.Errorf("server %s: unknown node %s: %w", id, name, orchestrator.ErrNotFound)
+	}
+	return n, local, nil
+}
+
+// nodeErr names the node in an error while keeping it matchable with
+// errors.Is.
+func nodeErr(n Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("node %s: %w", n.Name, err)
+}
+
+func (p *Provider) toGlobal(n Node, s orchestrator.Server) orchestrator.Server {
+	s.ID = ServerID(n.Name, s.ID)
+	labels := make(map[string]string, len(s.Labels)+1)
+	for k, v := range s.Labels {
+		labels[k] = v
+	}
+	labels[LabelNode] = n.Name
+	s.Labels = labels
+	return s
+}
+
+// List queries every node concurrently. If some nodes fail, the servers
+// from the others are still returned, along with an error naming the
+// failed nodes.
+func (p *Provider) List(ctx context.Context, filter map[string]string) ([]orchestrator.Server, error) {
+	nodes := p.nodes
+	local := filter
+	if name, ok := filter[LabelNode]; ok {
+		nodes = nil
+		if n, known := p.byName[name]; known {
+			nodes = []Node{n}
+		}
+		local = make(map[string]string, len(filter))
+		for k, v := range filter {
+			if k != LabelNode {
+				local[k] = v
+			}
+		}
+	}
+
+	results := make([][]orchestrator.Server, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			servers, err := n.Provider.List(ctx, local)
+			if err != nil {
+				errs[i] = nodeErr(n, err)
+				return
+			}
+			for _, s := range servers {
+				results[i] = append(results[i], p.toGlobal(n, s))
+			}
+		}()
+	}
+	wg.Wait()
+
+	servers := []orchestrator.Server{}
+	for _, r := range results {
+		servers = append(servers, r...)
+	}
+	return servers, errors.Join(errs...)
+}
+
+func (p *Provider) Get(ctx context.Context, id string) (*orchestrator.Server, error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		return nil, err
+	}
+	s, err := n.Provider.Get(ctx, local)
+	if err != nil {
+		return nil, nodeErr(n, err)
+	}
+	server := p.toGlobal(n, *s)
+	return &server, nil
+}
+
+// Allocate places the server (see Strategy and the placement labels)
+// and allocates it there. If the chosen node fails with
+// ErrResourceExhausted or ErrUnavailable, placement is retried without
+// it. Nodes are chosen one request at a time and the choice is reserved
+// until the node's Allocate returns, so concurrent requests can't both
+// claim the last of a node's capacity, while a slow node doesn't hold up
+// placements elsewhere.
+func (p *Provider) Allocate(ctx context.Context, req orchestrator.AllocateRequest) (*orchestrator.Server, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	constraints, err := parseConstraints(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]bool{}
+	var errs []error
+	for {
+		n, r, err := p.reserve(ctx, req, constraints, excluded)
+		if err != nil {
+			return nil, errors.Join(append(errs, err)...)
+		}
+		s, err := n.Provider.Allocate(ctx, req)
+		p.release(n, r)
+		if err == nil {
+			server := p.toGlobal(n, *s)
+			return &server, nil
+		}
+		if !errors.Is(err, orchestrator.ErrResourceExhausted) && !errors.Is(err, orchestrator.ErrUnavailable) {
+			return nil, nodeErr(n, err)
+		}
+		errs = append(errs, nodeErr(n, err))
+		excluded[n.Name] = true
+	}
+}
+
+// reserve chooses a node for req and records the reservation there.
+func (p *Provider) reserve(ctx context.Context, req orchestrator.AllocateRequest, cons constraints, excluded map[string]bool) (Node, *reservation, error) {
+	p.place.Lock()
+	defer p.place.Unlock()
+
+	candidates, err := p.candidates(ctx, req, cons, excluded)
+	if err != nil {
+		return Node{}, nil, err
+	}
+	chosen, err := p.strategy.Place(ctx, req, candidates)
+	if err != nil {
+		return Node{}, nil, err
+	}
+	r := &reservation{memory: req.MemoryLimit, cpus: req.CPULimit, labels: req.Labels}
+	p.pending[chosen.Node.Name] = append(p.pending[chosen.Node.Name], r)
+	return chosen.Node, r, nil
+}
+
+// release drops a reservation once its Allocate has returned. A server
+// that was created is in the node's List from then on.
+func (p *Provider) release(n Node, r *reservation) {
+	p.place.Lock()
+	defer p.place.Unlock()
+
+	pending := p.pending[n.Name]
+	for i, other := range pending {
+		if other == r {
+			p.pending[n.Name] = append(pending[:i:i], pending[i+1:]...)
+			break
+		}
+	}
+	if len(p.pending[n.Name]) == 0 {
+		delete(p.pending, n.Name)
+	}
+}
+
+func (p *Provider) Deallocate(ctx context.Context, id string) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.Deallocate(ctx, local))
+}
+
+func (p *Provider) DeallocateWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.DeallocateWithOptions(ctx, local, opts))
+}
+
+func (p *Provider) Restart(ctx context.Context, id string) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.Restart(ctx, local))
+}
+
+func (p *Provider) RestartWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.RestartWithOptions(ctx, local, opts))
+}
+
+func (p *Provider) Pause(ctx context.Context, id string) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.Pause(ctx, local))
+}
+
+func (p *Provider) Resume(ctx context.Context, id string) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.Resume(ctx, local))
+}
+
+func (p *Provider) UpdateResources(ctx context.Context, id string, update orchestrator.ResourceUpdate) error {
+	n, local, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return nodeErr(n, n.Provider.UpdateResources(ctx, local, update))
+}
+
+func (p *Provider) Exec(ctx context.Context, id string, cmd []string) (string, error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		return "", err
+	}
+	out, err := n.Provider.Exec(ctx, local, cmd)
+	return out, nodeErr(n, err)
+}
+
+func (p *Provider) ExecWithOptions(ctx context.Context, id string, cmd []string, opts orchestrator.ExecOptions) (*orchestrator.ExecResult, error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := n.Provider.ExecWithOptions(ctx, local, cmd, opts)
+	return result, nodeErr(n, err)
+}
+
+func (p *Provider) Logs(ctx context.Context, id string, tail int) (string, error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		return "", err
+	}
+	logs, err := n.Provider.Logs(ctx, local, tail)
+	return logs, nodeErr(n, err)
+}
+
+func (p *Provider) StreamLogs(ctx context.Context, id string, opts orchestrator.LogOptions) (<-chan orchestrator.LogLine, <-chan error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		lineCh := make(chan orchestrator.LogLine)
+		errCh := make(chan error, 1)
+		errCh <- err
+		close(lineCh)
+		close(errCh)
+		return lineCh, errCh
+	}
+	return n.Provider.StreamLogs(ctx, local, opts)
+}
+
+// Events merges the event streams of every node that is an
+// EventSource, prefixing server IDs with the node. Errors from a node
+// are forwarded with its name; the merged channels close once every
+// node's stream has closed.
+func (p *Provider) Events(ctx context.Context) (<-chan orchestrator.ContainerEvent, <-chan error) {
+	eventCh := make(chan orchestrator.ContainerEvent, 64)
+	errCh := make(chan error, len(p.nodes))
+
+	var wg sync.WaitGroup
+	for _, n := range p.nodes {
+		src, ok := n.Provider.(orchestrator.EventSource)
+		if !ok {
+			continue
+		}
+		events, errs := src.Events(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for events != nil || errs != nil {
+				select {
+				case e, ok := <-events:
+					if !ok {
+						events = nil
+						continue
+					}
+					e.ContainerID = ServerID(n.Name, e.ContainerID)
+					select {
+					case eventCh <- e:
+					case <-ctx.Done():
+					}
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
+					}
+					select {
+					case errCh <- nodeErr(n, err):
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(eventCh)
+		close(errCh)
+	}()
+	return eventCh, errCh
+}
+
+// Stats routes to the node's provider, which must be a StatsSource.
+func (p *Provider) Stats(ctx context.Context, id string) (*orchestrator.ContainerStats, error) {
+	n, local, err := p.route(id)
+	if err != nil {
+		return nil, err
+	}
+	src, ok := n.Provider.(orchestrator.StatsSource)
+	if !ok {
+		return nil, fmt.Errorf("node %s does not report stats", n.Name)
+	}
+	stats, err := src.Stats(ctx, local)
+	if err != nil {
+		return nil, nodeErr(n, err)
+	}
+	stats.ContainerID = ServerID(n.Name, stats.ContainerID)
+	return stats, nil
+}
+
+// StatsAll gathers stats from every node that reports them. Like List,
+// it returns what it could collect alongside an error for failed nodes.
+func (p *Provider) StatsAll(ctx context.Context) ([]orchestrator.ContainerStats, error) {
+	var (
+		mu   sync.Mutex
+		all  []orchestrator.ContainerStats
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, n := range p.nodes {
+		src, ok := n.Provider.(orchestrator.StatsSource)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats, err := src.StatsAll(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, nodeErr(n, err))
+				return
+			}
+			for _, s := range stats {
+				s.ContainerID = ServerID(n.Name, s.ContainerID)
+				all = append(all, s)
+			}
+		}()
+	}
+	wg.Wait()
+	return all, errors.Join(errs...)
+}
@@ -0,0 +1,310 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+)
+
+const gib = 1 << 30
+
+// statsNode adds canned stats to a fake so LeastLoaded can read load.
+type statsNode struct {
+	*orchestratortest.Provider
+	cpu float64
+}
+
+func (s *statsNode) Stats(ctx context.Context, id string) (*orchestrator.ContainerStats, error) {
+	return &orchestrator.ContainerStats{ContainerID: id, CPUPercent: s.cpu}, nil
+}
+
+func (s *statsNode) StatsAll(ctx context.Context) ([]orchestrator.ContainerStats, error) {
+	return []orchestrator.ContainerStats{{ContainerID: "any", CPUPercent: s.cpu}}, nil
+}
+
+// slowNode holds each Allocate until release is closed, signalling
+// started once it is in flight.
+type slowNode struct {
+	*orchestratortest.Provider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowNode) Allocate(ctx context.Context, req orchestrator.AllocateRequest) (*orchestrator.Server, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Provider.Allocate(ctx, req)
+}
+
+func newTestProvider(t *testing.T, nodes []Node, opts ...Option) *Provider {
+	t.Helper()
+	p, err := New(nodes, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func allocateOn(t *testing.T, p *Provider, req orchestrator.AllocateRequest) string {
+	t.Helper()
+	s, err := p.Allocate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Allocate(%s): %v", req.Name, err)
+	}
+	return s.Labels[LabelNode]
+}
+
+func TestRouting(t *testing.T) {
+	ctx := context.Background()
+	a, b := orchestratortest.New(), orchestratortest.New()
+	p := newTestProvider(t, []Node{{Name: "a", Provider: a}, {Name: "b", Provider: b}})
+
+	s1, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "one", Image: "img"})
+	s2, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "two", Image: "img"})
+	// Both fakes number their servers from 1, so only the prefix tells them apart
+	if s1.ID != "a/fake-000001" || s2.ID != "b/fake-000001" {
+		t.Fatalf("ids = %s, %s", s1.ID, s2.ID)
+	}
+
+	if err := p.Restart(ctx, s2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if a.Calls(orchestratortest.OpRestart) != 0 || b.Calls(orchestratortest.OpRestart) != 1 {
+		t.Error("restart not routed to node b")
+	}
+	got, err := p.Get(ctx, s2.ID)
+	if err != nil || got.Name != "two" || got.Labels[LabelNode] != "b" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+
+	listed := b.Calls(orchestratortest.OpList)
+	if list, _ := p.List(ctx, map[string]string{LabelNode: "a"}); len(list) != 1 || list[0].ID != s1.ID {
+		t.Errorf("List(node=a) = %+v", list)
+	}
+	if b.Calls(orchestratortest.OpList) != listed {
+		t.Error("node filter still queried node b")
+	}
+
+	for _, id := range []string{"fake-000001", "c/fake-000001"} {
+		if _, err := p.Get(ctx, id); !errors.Is(err, orchestrator.ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestListPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	a, b := orchestratortest.New(), orchestratortest.New()
+	p := newTestProvider(t, []Node{{Name: "a", Provider: a}, {Name: "b", Provider: b}})
+	allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"})
+	allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"})
+
+	down := errors.New("daemon down")
+	b.FailNext(orchestratortest.OpList, down)
+	list, err := p.List(ctx, nil)
+	if len(list) != 1 || !errors.Is(err, down) {
+		t.Errorf("List = %d servers, %v", len(list), err)
+	}
+}
+
+func TestBinPack(t *testing.T) {
+	p := newTestProvider(t, []Node{
+		{Name: "big", Provider: orchestratortest.New(), Capacity: Capacity{MemoryBytes: 16 * gib}},
+		{Name: "small", Provider: orchestratortest.New(), Capacity: Capacity{MemoryBytes: 4 * gib}},
+	}, WithStrategy(BinPack()))
+
+	req := orchestrator.AllocateRequest{Image: "img", MemoryLimit: 2 * gib}
+	var placed []string
+	for range 3 {
+		placed = append(placed, allocateOn(t, p, req))
+	}
+	// The small node is filled first, then the big one
+	if placed[0] != "small" || placed[1] != "small" || placed[2] != "big" {
+		t.Errorf("placements = %v", placed)
+	}
+
+	_, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{Image: "img", MemoryLimit: 15 * gib})
+	if !errors.Is(err, orchestrator.ErrResourceExhausted) {
+		t.Errorf("oversized request = %v, want ErrResourceExhausted", err)
+	}
+}
+
+func TestConcurrentAllocateRespectsCapacity(t *testing.T) {
+	p := newTestProvider(t, []Node{
+		{Name: "a", Provider: orchestratortest.New(), Capacity: Capacity{MemoryBytes: 4 * gib}},
+		{Name: "b", Provider: orchestratortest.New(), Capacity: Capacity{MemoryBytes: 4 * gib}},
+	})
+
+	req := orchestrator.AllocateRequest{Image: "img", MemoryLimit: 2 * gib}
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			_, err := p.Allocate(context.Background(), req)
+			errs <- err
+		}()
+	}
+	placed := 0
+	for range cap(errs) {
+		err := <-errs
+		switch {
+		case err == nil:
+			placed++
+		case !errors.Is(err, orchestrator.ErrResourceExhausted):
+			t.Errorf("Allocate = %v", err)
+		}
+	}
+	if placed != 4 {
+		t.Errorf("placed %d servers, want 4 (two per node)", placed)
+	}
+
+	// A node still pulling and starting a server keeps its capacity
+	// reserved without holding up placements on other nodes
+	slow := &slowNode{orchestratortest.New(), make(chan struct{}, 1), make(chan struct{})}
+	p = newTestProvider(t, []Node{
+		{Name: "slow", Provider: slow, Labels: map[string]string{"disk": "slow"}, Capacity: Capacity{MemoryBytes: 2 * gib}},
+		{Name: "fast", Provider: orchestratortest.New(), Labels: map[string]string{"disk": "fast"}},
+	})
+	onSlow := orchestrator.AllocateRequest{Image: "img", MemoryLimit: 2 * gib, Labels: map[string]string{LabelNodeSelector: "disk=slow"}}
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := p.Allocate(context.Background(), onSlow)
+		slowDone <- err
+	}()
+	<-slow.started
+
+	if _, err := p.Allocate(context.Background(), onSlow); !errors.Is(err, orchestrator.ErrResourceExhausted) {
+		t.Errorf("Allocate over a pending reservation = %v, want ErrResourceExhausted", err)
+	}
+	fastDone := make(chan string, 1)
+	go func() {
+		s, err := p.Allocate(context.Background(), req)
+		if err != nil {
+			fastDone <- err.Error()
+			return
+		}
+		fastDone <- s.Labels[LabelNode]
+	}()
+	select {
+	case node := <-fastDone:
+		if node != "fast" {
+			t.Errorf("placed on %s while slow was full, want fast", node)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Allocate on fast blocked behind the slow node")
+	}
+
+	close(slow.release)
+	if err := <-slowDone; err != nil {
+		t.Errorf("slow Allocate = %v", err)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	p := newTestProvider(t, []Node{
+		{Name: "a", Provider: orchestratortest.New()},
+		{Name: "b", Provider: orchestratortest.New()},
+		{Name: "c", Provider: orchestratortest.New()},
+	})
+	counts := map[string]int{}
+	for range 6 {
+		counts[allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"})]++
+	}
+	if counts["a"] != 2 || counts["b"] != 2 || counts["c"] != 2 {
+		t.Errorf("spread = %v", counts)
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	p := newTestProvider(t, []Node{
+		{Name: "nostats", Provider: orchestratortest.New()},
+		{Name: "busy", Provider: &statsNode{orchestratortest.New(), 300}, Capacity: Capacity{CPUs: 4}},
+		{Name: "quiet", Provider: &statsNode{orchestratortest.New(), 150}},
+		{Name: "large", Provider: &statsNode{orchestratortest.New(), 300}, Capacity: Capacity{CPUs: 8}},
+	}, WithStrategy(LeastLoaded()))
+
+	// large runs 300% over 8 CPUs, below quiet's 150% over one
+	if node := allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"}); node != "large" {
+		t.Errorf("placed on %s, want large", node)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	p := newTestProvider(t, []Node{
+		{Name: "eu-1", Provider: orchestratortest.New(), Labels: map[string]string{"region": "eu"}},
+		{Name: "eu-2", Provider: orchestratortest.New(), Labels: map[string]string{"region": "eu"}},
+		{Name: "us-1", Provider: orchestratortest.New(), Labels: map[string]string{"region": "us"}},
+	})
+
+	inEU := func(labels map[string]string) orchestrator.AllocateRequest {
+		labels[LabelNodeSelector] = "region=eu"
+		return orchestrator.AllocateRequest{Image: "img", Labels: labels}
+	}
+
+	// Anti-affinity keeps the two ranked matches on different EU nodes
+	r1 := allocateOn(t, p, inEU(map[string]string{"mode": "ranked", LabelAntiAffinity: "mode=ranked"}))
+	r2 := allocateOn(t, p, inEU(map[string]string{"mode": "ranked", LabelAntiAffinity: "mode=ranked"}))
+	if r1 == r2 || r1 == "us-1" || r2 == "us-1" {
+		t.Errorf("ranked placed on %s and %s", r1, r2)
+	}
+	_, err := p.Allocate(context.Background(), inEU(map[string]string{LabelAntiAffinity: "mode=ranked"}))
+	if !errors.Is(err, orchestrator.ErrResourceExhausted) {
+		t.Errorf("third ranked = %v, want ErrResourceExhausted", err)
+	}
+
+	// Affinity follows the party even once its node is the busiest
+	party := allocateOn(t, p, orchestrator.AllocateRequest{Image: "img", Labels: map[string]string{"party": "p1"}})
+	for range 2 {
+		node := allocateOn(t, p, orchestrator.AllocateRequest{Image: "img", Labels: map[string]string{LabelAffinity: "party=p1"}})
+		if node != party {
+			t.Errorf("affine server on %s, party on %s", node, party)
+		}
+	}
+
+	if _, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{Image: "img", Labels: map[string]string{LabelNodeSelector: ","}}); err == nil {
+		t.Error("accepted an empty node selector")
+	}
+}
+
+func TestAllocateFailsOver(t *testing.T) {
+	a, b := orchestratortest.New(), orchestratortest.New()
+	p := newTestProvider(t, []Node{{Name: "a", Provider: a}, {Name: "b", Provider: b}})
+
+	a.FailNext(orchestratortest.OpAllocate, orchestrator.ErrUnavailable)
+	if node := allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"}); node != "b" {
+		t.Errorf("placed on %s after a failed, want b", node)
+	}
+
+	a.FailNext(orchestratortest.OpAllocate, errors.New("bad image"))
+	if _, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{Image: "img"}); err == nil {
+		t.Error("other errors should not fail over")
+	}
+	if b.Calls(orchestratortest.OpAllocate) != 1 {
+		t.Errorf("node b allocations = %d, want 1", b.Calls(orchestratortest.OpAllocate))
+	}
+}
+
+func TestEventsMerged(t *testing.T) {
+	a, b := orchestratortest.New(), orchestratortest.New()
+	p := newTestProvider(t, []Node{{Name: "a", Provider: a}, {Name: "b", Provider: b}})
+	events, _ := p.Events(t.Context())
+
+	allocateOn(t, p, orchestrator.AllocateRequest{Image: "img"})
+	s, _ := p.Allocate(context.Background(), orchestrator.AllocateRequest{Image: "img"})
+	_, local, _ := SplitID(s.ID)
+	if err := b.Exit(local, 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for e := range events {
+		if e.Action == orchestrator.EventDie {
+			if e.ContainerID != s.ID || e.ExitCode != 1 || !e.OOMKilled {
+				t.Errorf("die event = %+v", e)
+			}
+			break
+		}
+	}
+}
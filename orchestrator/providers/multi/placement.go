@@ -0,0 +1,322 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Placement constraints are read from the request's labels, so they
+// travel with specs and are visible on the servers afterwards. Each
+// value is a comma-separated selector using List semantics: "k=v"
+// requires equality, a bare "k" only presence.
+const (
+	// LabelNodeSelector restricts placement to nodes whose labels
+	// match, e.g. "region=eu,disk=nvme".
+	LabelNodeSelector = "potassium.node-selector"
+	// LabelAffinity prefers nodes already running a server whose labels
+	// match, e.g. "party=abc123" to co-locate a party's servers. When no
+	// node has one, every node is eligible.
+	LabelAffinity = "potassium.affinity"
+	// LabelAntiAffinity excludes nodes running a server whose labels
+	// match, e.g. "mode=ranked" to keep ranked matches apart.
+	LabelAntiAffinity = "potassium.anti-affinity"
+)
+
+// Candidate is a node eligible for a request, with what is already
+// allocated on it.
+type Candidate struct {
+	Node    Node
+	Servers []orchestrator.Server
+	// Pending counts Allocates in flight on the node, whose servers may
+	// not be in Servers yet.
+	Pending int
+	// MemoryAllocated and CPUAllocated sum the limits of Servers and of
+	// the pending Allocates.
+	MemoryAllocated int64
+	CPUAllocated    float64
+}
+
+// Strategy chooses a node for a request. Candidates are never empty,
+// already satisfy the request's constraints and have room for it, and
+// are in the order the nodes were given to New.
+type Strategy interface {
+	Place(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error)
+}
+
+// StrategyFunc adapts a function to Strategy.
+type StrategyFunc func(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error)
+
+func (f StrategyFunc) Place(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error) {
+	return f(ctx, req, candidates)
+}
+
+// Spread places on the node with the fewest servers, counting pending
+// ones.
+func Spread() Strategy {
+	return StrategyFunc(func(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error) {
+		best := candidates[0]
+		for _, c := range candidates[1:] {
+			if len(c.Servers)+c.Pending < len(best.Servers)+best.Pending {
+				best = c
+			}
+		}
+		return best, nil
+	})
+}
+
+// BinPack fills nodes before using new ones: it places on the node
+// left with the least free memory (then CPU) after the allocation,
+// keeping whole nodes free for large servers or scale-down. Nodes
+// without a capacity count as emptiest.
+func BinPack() Strategy {
+	return StrategyFunc(func(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error) {
+		type scored struct {
+			c        Candidate
+			limited  bool
+			freeMem  int64
+			freeCPUs float64
+		}
+		var all []scored
+		for _, c := range candidates {
+			capacity := c.Node.Capacity
+			all = append(all, scored{
+				c:        c,
+				limited:  capacity.MemoryBytes > 0 || capacity.CPUs > 0,
+				freeMem:  capacity.MemoryBytes - c.MemoryAllocated - req.MemoryLimit,
+				freeCPUs: capacity.CPUs - c.CPUAllocated - req.CPULimit,
+			})
+		}
+		sort.SliceStable(all, func(i, j int) bool {
+			a, b := all[i], all[j]
+			if a.limited != b.limited {
+				return a.limited
+			}
+			if a.freeMem != b.freeMem {
+				return a.freeMem < b.freeMem
+			}
+			return a.freeCPUs < b.freeCPUs
+		})
+		return all[0].c, nil
+	})
+}
+
+// LeastLoaded places on the node using the least CPU right now, from
+// its provider's StatsAll, with memory in use as the tie-breaker. CPU
+// is divided by Capacity.CPUs when set so bigger hosts take more.
+// Nodes that can't report stats are only used when none can.
+func LeastLoaded() Strategy {
+	return StrategyFunc(func(ctx context.Context, req orchestrator.AllocateRequest, candidates []Candidate) (Candidate, error) {
+		type load struct {
+			ok     bool
+			cpu    float64
+			memory int64
+		}
+		loads := make([]load, len(candidates))
+		var wg sync.WaitGroup
+		for i, c := range candidates {
+			src, ok := c.Node.Provider.(orchestrator.StatsSource)
+			if !ok {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stats, err := src.StatsAll(ctx)
+				if err != nil {
+					return
+				}
+				l := load{ok: true}
+				for _, s := range stats {
+					l.cpu += s.CPUPercent
+					l.memory += s.MemoryUsed
+				}
+				if cpus := c.Node.Capacity.CPUs; cpus > 0 {
+					l.cpu /= cpus
+				}
+				loads[i] = l
+			}()
+		}
+		wg.Wait()
+
+		best := 0
+		for i := 1; i < len(candidates); i++ {
+			a, b := loads[i], loads[best]
+			switch {
+			case a.ok != b.ok:
+				if a.ok {
+					best = i
+				}
+			case a.cpu != b.cpu:
+				if a.cpu < b.cpu {
+					best = i
+				}
+			case a.memory < b.memory:
+				best = i
+			}
+		}
+		return candidates[best], nil
+	})
+}
+
+// constraints are the parsed placement labels of a request.
+type constraints struct {
+	nodeSelector map[string]string
+	affinity     map[string]string
+	antiAffinity map[string]string
+}
+
+func parseConstraints(labels map[string]string) (constraints, error) {
+	var c constraints
+	var errs []error
+	for key, dst := range map[string]*map[string]string{
+		LabelNodeSelector: &c.nodeSelector,
+		LabelAffinity:     &c.affinity,
+		LabelAntiAffinity: &c.antiAffinity,
+	} {
+		value, ok := labels[key]
+		if !ok {
+			continue
+		}
+		selector, err := ParseSelector(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("label %s: %w", key, err))
+		}
+		*dst = selector
+	}
+	return c, errors.Join(errs...)
+}
+
+// ParseSelector parses "k=v,k2" into a label filter for
+// orchestrator.MatchLabels.
+func ParseSelector(s string) (map[string]string, error) {
+	selector := map[string]string{}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(term, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("empty key in selector %q", s)
+		}
+		selector[key] = strings.TrimSpace(value)
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("empty selector %q", s)
+	}
+	return selector, nil
+}
+
+// candidates lists the nodes that can take req: reachable, matching the
+// node selector, free of anti-affinity conflicts, with capacity to
+// spare and, if any node satisfies the affinity, only those nodes.
+// Pending reservations count as servers on their node. The caller holds
+// p.place.
+func (p *Provider) candidates(ctx context.Context, req orchestrator.AllocateRequest, cons constraints, excluded map[string]bool) ([]Candidate, error) {
+	type result struct {
+		c      Candidate
+		listed bool
+		err    error
+	}
+	results := make([]result, len(p.nodes))
+	var wg sync.WaitGroup
+	for i, n := range p.nodes {
+		if excluded[n.Name] || (cons.nodeSelector != nil && !orchestrator.MatchLabels(n.Labels, cons.nodeSelector)) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			servers, err := n.Provider.List(ctx, nil)
+			if err != nil {
+				results[i].err = nodeErr(n, err)
+				return
+			}
+			c := Candidate{Node: n, Servers: servers}
+			for _, s := range servers {
+				c.MemoryAllocated += s.MemoryLimit
+				c.CPUAllocated += s.CPULimit
+			}
+			results[i] = result{c: c, listed: true}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		eligible []Candidate
+		affine   []Candidate
+		reasons  []error
+	)
+	for _, r := range results {
+		c := r.c
+		pending := p.pending[c.Node.Name]
+		c.Pending = len(pending)
+		for _, res := range pending {
+			c.MemoryAllocated += res.memory
+			c.CPUAllocated += res.cpus
+		}
+		switch {
+		case r.err != nil:
+			reasons = append(reasons, r.err)
+			continue
+		case !r.listed:
+			continue
+		case cons.antiAffinity != nil && (anyMatch(c.Servers, cons.antiAffinity) || anyPending(pending, cons.antiAffinity)):
+			reasons = append(reasons, fmt.Errorf("node %s: anti-affinity %v", c.Node.Name, cons.antiAffinity))
+			continue
+		case !fits(c, req):
+			reasons = append(reasons, fmt.Errorf("node %s: insufficient capacity", c.Node.Name))
+			continue
+		}
+		eligible = append(eligible, c)
+		if cons.affinity != nil && (anyMatch(c.Servers, cons.affinity) || anyPending(pending, cons.affinity)) {
+			affine = append(affine, c)
+		}
+	}
+
+	if len(affine) > 0 {
+		return affine, nil
+	}
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no node can place server: %w", errors.Join(append([]error{orchestrator.ErrResourceExhausted}, reasons...)...))
+	}
+	return eligible, nil
+}
+
+func anyMatch(servers []orchestrator.Server, selector map[string]string) bool {
+	for _, s := range servers {
+		if orchestrator.MatchLabels(s.Labels, selector) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPending(pending []*reservation, selector map[string]string) bool {
+	for _, r := range pending {
+		if orchestrator.MatchLabels(r.labels, selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// fits reports whether req's limits fit in what's left of the node's
+// capacity.
+func fits(c Candidate, req orchestrator.AllocateRequest) bool {
+	capacity := c.Node.Capacity
+	if capacity.MemoryBytes > 0 && c.MemoryAllocated+req.MemoryLimit > capacity.MemoryBytes {
+		return false
+	}
+	if capacity.CPUs > 0 && c.CPUAllocated+req.CPULimit > capacity.CPUs {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Exec runs cmd in the server directory and returns its stdout.
+func (p *Provider) Exec(ctx context.Context, id string, cmd []string) (string, error) {
+	result, err := p.ExecWithOptions(ctx, id, cmd, orchestrator.ExecOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("exec exit code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+// ExecWithOptions runs cmd as a subprocess with the server's environment
+// in its working directory (opts.WorkingDir is a container path inside
+// the server directory). The server must be running. On timeout the
+// command's process group is killed.
+func (p *Provider) ExecWithOptions(ctx context.Context, id string, cmd []string, opts orchestrator.ExecOptions) (*orchestrator.ExecResult, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("exec needs a command")
+	}
+	if opts.User != "" {
+		return nil, errors.New("process provider does not support exec user")
+	}
+	if opts.TTY {
+		return nil, errors.New("process provider does not support exec tty")
+	}
+
+	p.mu.Lock()
+	s, ok := p.servers[id]
+	if !ok {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if s.info.Status != orchestrator.StatusRunning {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("server %s is %s: %w", id, s.info.Status, orchestrator.ErrNotRunning)
+	}
+	dir, env := s.workDir, s.env
+	if opts.WorkingDir != "" {
+		dir = inDir(s.dir, opts.WorkingDir)
+	}
+	p.mu.Unlock()
+
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(runCtx, lookPath(cmd[0], dir), cmd[1:]...)
+	c.Dir = dir
+	c.Env = append([]string{}, env...)
+	for key, value := range opts.Env {
+		c.Env = append(c.Env, key+"="+value)
+	}
+	if opts.Stdin != nil {
+		c.Stdin = bytes.NewReader(opts.Stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	c.WaitDelay = outputWaitDelay
+	cancelGroup(c)
+
+	start := time.Now()
+	err := c.Run()
+	result := &orchestrator.ExecResult{
+		ExitCode: -1,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	if runCtx.Err() != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, fmt.Errorf("exec timed out after %s: %w", opts.Timeout, context.DeadlineExceeded)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("exec failed: %w", err)
+	}
+	result.ExitCode = exitCode(c.ProcessState)
+	return result, nil
+}
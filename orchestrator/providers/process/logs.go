@@ -0,0 +1,350 @@
+package process
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Log rotation defaults, matching Docker's json-file driver settings we
+// recommend for game servers.
+const (
+	defaultLogMaxBytes = 10 << 20
+	defaultLogMaxFiles = 3
+
+	followBuffer = 1024
+)
+
+// logFile records a server's output as JSON lines, one LogLine each,
+// in path. When it grows past maxBytes it is renamed to path.1 (older
+// files shift up) and at most maxFiles rotated files are kept.
+type logFile struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	followers map[chan orchestrator.LogLine]struct{}
+}
+
+func openLog(path string, maxBytes int64, maxFiles int) (*logFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &logFile{
+		path:      path,
+		maxBytes:  maxBytes,
+		maxFiles:  maxFiles,
+		f:         f,
+		size:      info.Size(),
+		followers: make(map[chan orchestrator.LogLine]struct{}),
+	}, nil
+}
+
+// write appends a line and hands it to followers. Followers that fall
+// more than followBuffer lines behind miss lines rather than stalling
+// the server's output.
+func (l *logFile) write(line orchestrator.LogLine) {
+	data, _ := json.Marshal(line)
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return
+	}
+	if l.size > 0 && l.size+int64(len(data)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return
+		}
+	}
+	n, _ := l.f.Write(data)
+	l.size += int64(n)
+
+	for ch := range l.followers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1 and starts a
+// new file. Callers must hold l.mu.
+func (l *logFile) rotate() error {
+	l.f.Close()
+	os.Remove(l.rotated(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(l.rotated(i), l.rotated(i+1))
+	}
+	if l.maxFiles > 0 {
+		os.Rename(l.path, l.rotated(1))
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		l.f = nil
+		return err
+	}
+	l.f = f
+	l.size = 0
+	return nil
+}
+
+func (l *logFile) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// history reads every retained line, oldest first.
+func (l *logFile) history() ([]orchestrator.LogLine, error) {
+	read, _, err := l.snapshot(false)
+	if err != nil {
+		return nil, err
+	}
+	return read()
+}
+
+func appendLines(lines []orchestrator.LogLine, r io.Reader) ([]orchestrator.LogLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var line orchestrator.LogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// snapshot opens the retained files and, when follow is set, returns a
+// channel of lines written after them. Opening and subscribing under
+// the same lock means no line is missed or repeated between them. The
+// history itself is read by the returned function, which holds no lock,
+// so a long history doesn't block write and with it the server's output
+// pipes: open handles survive rotation, and the current file is cut at
+// its present size so later lines aren't read twice.
+func (l *logFile) snapshot(follow bool) (func() ([]orchestrator.LogLine, error), chan orchestrator.LogLine, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	var readers []io.Reader
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotated(i)
+		}
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		if i == 0 {
+			readers = append(readers, io.LimitReader(f, l.size))
+		} else {
+			readers = append(readers, f)
+		}
+	}
+	read := func() ([]orchestrator.LogLine, error) {
+		defer closeFiles()
+		var lines []orchestrator.LogLine
+		for _, r := range readers {
+			var err error
+			if lines, err = appendLines(lines, r); err != nil {
+				return nil, err
+			}
+		}
+		return lines, nil
+	}
+
+	if !follow {
+		return read, nil, nil
+	}
+	ch := make(chan orchestrator.LogLine, followBuffer)
+	l.followers[ch] = struct{}{}
+	return read, ch, nil
+}
+
+func (l *logFile) unfollow(ch chan orchestrator.LogLine) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.followers[ch]; ok {
+		delete(l.followers, ch)
+		close(ch)
+	}
+}
+
+// endFollowers closes every follow channel; called when the server
+// exits so followers stop like Docker's do.
+func (l *logFile) endFollowers() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.followers {
+		delete(l.followers, ch)
+		close(ch)
+	}
+}
+
+func (l *logFile) close() {
+	l.endFollowers()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+}
+
+// pump copies one output stream of a run into the log line by line.
+func (l *logFile) pump(r io.Reader, stream orchestrator.LogStream) {
+	br := bufio.NewReader(r)
+	for {
+		text, err := br.ReadString('\n')
+		if text != "" {
+			l.write(orchestrator.LogLine{
+				Time:   time.Now(),
+				Stream: stream,
+				Text:   strings.TrimRight(text, "\r\n"),
+			})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Logs returns the last tail lines of the server's output (all of it
+// when tail <= 0), stdout and stderr interleaved.
+func (p *Provider) Logs(ctx context.Context, id string, tail int) (string, error) {
+	s, err := p.server(id)
+	if err != nil {
+		return "", err
+	}
+
+	lines, err := s.log.history()
+	if err != nil {
+		return "", fmt.Errorf("reading logs failed: %w", err)
+	}
+
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// StreamLogs replays the server's retained output and, when following,
+// delivers new lines until the context is cancelled or the server
+// exits.
+func (p *Provider) StreamLogs(ctx context.Context, id string, opts orchestrator.LogOptions) (<-chan orchestrator.LogLine, <-chan error) {
+	lineCh := make(chan orchestrator.LogLine, 64)
+	errCh := make(chan error, 1)
+
+	p.mu.Lock()
+	s, ok := p.servers[id]
+	var (
+		readHistory func() ([]orchestrator.LogLine, error)
+		live        chan orchestrator.LogLine
+		err         error
+	)
+	if !ok {
+		err = fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	} else {
+		readHistory, live, err = s.log.snapshot(opts.Follow && s.proc != nil)
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		errCh <- err
+		close(lineCh)
+		close(errCh)
+		return lineCh, errCh
+	}
+
+	go func() {
+		defer close(lineCh)
+		defer close(errCh)
+		if live != nil {
+			defer s.log.unfollow(live)
+		}
+
+		history, err := readHistory()
+		if err != nil {
+			errCh <- fmt.Errorf("reading logs failed: %w", err)
+			return
+		}
+		if opts.Tail > 0 && len(history) > opts.Tail {
+			history = history[len(history)-opts.Tail:]
+		}
+		send := func(line orchestrator.LogLine) bool {
+			if !opts.Since.IsZero() && line.Time.Before(opts.Since) {
+				return true
+			}
+			if !opts.Until.IsZero() && line.Time.After(opts.Until) {
+				return false
+			}
+			select {
+			case lineCh <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, line := range history {
+			if !send(line) {
+				return
+			}
+		}
+		if live == nil {
+			return
+		}
+		for {
+			select {
+			case line, ok := <-live:
+				if !ok || !send(line) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return lineCh, errCh
+}
@@ -0,0 +1,91 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	l, err := openLog(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	for i := range 20 {
+		l.write(orchestrator.LogLine{Time: time.Now(), Stream: orchestrator.StreamStdout, Text: fmt.Sprintf("line %02d", i)})
+	}
+
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Errorf("expected two rotated files: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("kept more rotated files than maxFiles")
+	}
+
+	lines, err := l.history()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) == 0 || len(lines) >= 20 {
+		t.Fatalf("history kept %d lines; want the newest few", len(lines))
+	}
+	if last := lines[len(lines)-1].Text; last != "line 19" {
+		t.Errorf("newest line = %q", last)
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i].Text < lines[i-1].Text {
+			t.Fatalf("history out of order at %d: %q after %q", i, lines[i].Text, lines[i-1].Text)
+		}
+	}
+}
+
+func TestStreamLogsFollow(t *testing.T) {
+	p, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := openLog(filepath.Join(t.TempDir(), "server.log"), defaultLogMaxBytes, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A server record without a process, standing in for a running one
+	s := &server{info: orchestrator.Server{ID: "s1"}, log: log}
+	p.servers["s1"] = s
+
+	log.write(orchestrator.LogLine{Text: "old 1"})
+	log.write(orchestrator.LogLine{Text: "old 2"})
+
+	lines, _ := p.StreamLogs(context.Background(), "s1", orchestrator.LogOptions{Tail: 1})
+	var got []string
+	for line := range lines {
+		got = append(got, line.Text)
+	}
+	if len(got) != 1 || got[0] != "old 2" {
+		t.Errorf("tail 1 = %v", got)
+	}
+
+	read, live, err := log.snapshot(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lines written before the history is read only arrive live
+	log.write(orchestrator.LogLine{Text: "new"})
+	if history, err := read(); err != nil || len(history) != 2 {
+		t.Fatalf("snapshot = %d lines, %v", len(history), err)
+	}
+	if line := <-live; line.Text != "new" {
+		t.Errorf("followed line = %q", line.Text)
+	}
+	log.endFollowers()
+	if _, ok := <-live; ok {
+		t.Error("follow channel open after server exit")
+	}
+}
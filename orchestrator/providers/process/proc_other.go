@@ -0,0 +1,51 @@
+//go:build !unix
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Without process groups only the server's own process is signalled.
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+var errNoSignals = errors.New("signals other than kill are only supported on unix")
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		return errNoSignals
+	}
+	return killGroup(pid)
+}
+
+func killGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func freezeGroup(pid int) error {
+	return errors.New("pause is only supported on unix")
+}
+
+func thawGroup(pid int) error {
+	return errors.New("resume is only supported on unix")
+}
+
+// parseSignal only knows SIGKILL here, so every stop is a kill.
+func parseSignal(name string) (syscall.Signal, error) {
+	return syscall.SIGKILL, nil
+}
+
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+func cancelGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,80 @@
+//go:build unix
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Servers run in their own process group so signals reach everything
+// the server spawned (wrapper scripts, JVM children) and not this
+// process.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+func killGroup(pid int) error {
+	return signalGroup(pid, syscall.SIGKILL)
+}
+
+// freezeGroup and thawGroup implement Pause and Resume with job control
+// signals, which can't be caught or ignored.
+func freezeGroup(pid int) error {
+	return signalGroup(pid, syscall.SIGSTOP)
+}
+
+func thawGroup(pid int) error {
+	return signalGroup(pid, syscall.SIGCONT)
+}
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// parseSignal accepts the forms Docker does: "SIGINT", "INT" or "2".
+// Empty means SIGTERM.
+func parseSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", name)
+}
+
+// exitCode reports how a run ended the way Docker does: the exit status,
+// or 128 plus the signal number when the process was killed.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// cancelGroup makes an exec's context cancellation kill the command's
+// whole process group.
+func cancelGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = sysProcAttr()
+	cmd.Cancel = func() error {
+		return killGroup(cmd.Process.Pid)
+	}
+}
@@ -0,0 +1,642 @@
+// Package process implements orchestrator.Provider with plain OS
+// processes, for bare-metal hosts and local development without Docker.
+//
+// Each server gets a directory under the provider's root. The command
+// runs there with the request's environment, its output is captured to
+// rotating log files, and the restart policy is applied when it exits.
+// Container paths in the request (WorkingDir, volume targets) are
+// resolved inside the server directory:
+//
+//	p, err := process.New("/var/lib/potassium/servers")
+//	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+//		Command:     []string{"java", "-jar", "/opt/hytale/server.jar"},
+//		Ports:       []orchestrator.PortBinding{{Container: 5520, Range: "5520-5599", Protocol: "udp"}},
+//		Volumes:     map[string]string{"/srv/worlds/lobby": "/world"},
+//		Environment: map[string]string{"SERVER_ID": "lobby-1"},
+//	})
+//
+// State lives in memory: servers don't outlive the provider, and Close
+// stops them all.
+package process
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Provider runs servers as child processes.
+type Provider struct {
+	root   string
+	hostIP string
+	ports  *orchestrator.PortAllocator
+
+	logMaxBytes int64
+	logMaxFiles int
+
+	mu          sync.Mutex
+	servers     map[string]*server
+	creating    map[string]string // ID -> name, reserved while Allocate sets up
+	subscribers map[chan orchestrator.ContainerEvent]struct{}
+	closed      bool
+}
+
+var _ orchestrator.Provider = (*Provider)(nil)
+var _ orchestrator.EventSource = (*Provider)(nil)
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithHostIP sets the address reported as Server.IP. Default 127.0.0.1.
+func WithHostIP(ip string) Option {
+	return func(p *Provider) {
+		p.hostIP = ip
+	}
+}
+
+// WithLogRotation sets the size at which a server's log file is rotated
+// and how many rotated files are kept. Default 10 MiB and 3.
+func WithLogRotation(maxBytes int64, maxFiles int) Option {
+	return func(p *Provider) {
+		p.logMaxBytes = maxBytes
+		p.logMaxFiles = maxFiles
+	}
+}
+
+// New creates a provider keeping server directories under root, which
+// is created if needed.
+func New(root string, opts ...Option) (*Provider, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		root:        root,
+		hostIP:      "127.0.0.1",
+		ports:       orchestrator.NewPortAllocator(),
+		logMaxBytes: defaultLogMaxBytes,
+		logMaxFiles: defaultLogMaxFiles,
+		servers:     make(map[string]*server),
+		creating:    make(map[string]string),
+		subscribers: make(map[chan orchestrator.ContainerEvent]struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// List returns servers matching filter (see orchestrator.Provider),
+// oldest first.
+func (p *Provider) List(ctx context.Context, filter map[string]string) ([]orchestrator.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	servers := []orchestrator.Server{}
+	for _, s := range p.servers {
+		if orchestrator.MatchLabels(s.info.Labels, filter) {
+			servers = append(servers, s.snapshot())
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return p.servers[servers[i].ID].created.Before(p.servers[servers[j].ID].created)
+	})
+	return servers, nil
+}
+
+func (p *Provider) Get(ctx context.Context, id string) (*orchestrator.Server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	server := s.snapshot()
+	return &server, nil
+}
+
+// Allocate creates the server directory and starts the process. The
+// command is Entrypoint followed by Command; when both are empty Image
+// is run as the executable.
+//
+// A process binds host ports itself, so every binding is exposed to it
+// as PORT_<container port> (and PORT_<NAME> for named bindings) set to
+// the host port: Host when given, otherwise one reserved from Range,
+// otherwise the container port itself.
+//
+// Volumes with an absolute host path are symlinked into the server
+// directory at their target; named volumes live under root/volumes.
+// Resource limits are recorded on the server but not enforced, and
+// isolation features that need a container runtime are rejected.
+func (p *Provider) Allocate(ctx context.Context, req orchestrator.AllocateRequest) (*orchestrator.Server, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := unsupported(req); err != nil {
+		return nil, err
+	}
+
+	argv := append(append([]string{}, req.Entrypoint...), req.Command...)
+	if len(argv) == 0 && req.Image != "" {
+		argv = []string{req.Image}
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("process provider needs a command, entrypoint or image to run")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	name := req.Name
+	if name == "" {
+		name = "server-" + id[:12]
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("process provider closed: %w", orchestrator.ErrUnavailable)
+	}
+	if p.nameTaken(name) {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("server name %q: %w", name, orchestrator.ErrAlreadyExists)
+	}
+	ports, err := p.assignPorts(req)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	// Hold the name while the directory is set up without the lock
+	p.creating[id] = name
+	p.mu.Unlock()
+
+	s, err := p.create(id, name, argv, ports, req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.creating, id)
+	if err == nil && p.closed {
+		// Close ran meanwhile and won't stop a server it didn't see
+		s.log.close()
+		os.RemoveAll(s.dir)
+		err = fmt.Errorf("process provider closed: %w", orchestrator.ErrUnavailable)
+	}
+	if err != nil {
+		p.releasePortsLocked(req, ports)
+		return nil, err
+	}
+
+	p.servers[id] = s
+	p.emit(s, orchestrator.EventCreate)
+	if err := p.start(s); err != nil {
+		// Nothing ran; don't leave a half-created server behind
+		delete(p.servers, id)
+		p.releasePortsLocked(req, ports)
+		p.emit(s, orchestrator.EventDestroy)
+		s.log.close()
+		os.RemoveAll(s.dir)
+		return nil, err
+	}
+	server := s.snapshot()
+	return &server, nil
+}
+
+// nameTaken reports whether a server, or one being allocated, already
+// uses name. Callers must hold p.mu.
+func (p *Provider) nameTaken(name string) bool {
+	for _, s := range p.servers {
+		if s.info.Name == name {
+			return true
+		}
+	}
+	for _, n := range p.creating {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// create lays out the server directory and builds everything needed to
+// start it.
+func (p *Provider) create(id, name string, argv []string, ports map[string]int, req orchestrator.AllocateRequest) (*server, error) {
+	dir := filepath.Join(p.root, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	cleanup := func(err error) (*server, error) {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	for source, target := range req.Volumes {
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(p.root, "volumes", source)
+		}
+		if err := os.MkdirAll(source, 0o755); err != nil {
+			return cleanup(fmt.Errorf("volume %s: %w", source, err))
+		}
+		link := inDir(dir, target)
+		if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+			return cleanup(err)
+		}
+		if err := os.Symlink(source, link); err != nil {
+			return cleanup(fmt.Errorf("volume %s: %w", target, err))
+		}
+	}
+
+	workDir := dir
+	if req.WorkingDir != "" {
+		workDir = inDir(dir, req.WorkingDir)
+		if err := os.MkdirAll(workDir, 0o755); err != nil {
+			return cleanup(err)
+		}
+	}
+
+	logDir := filepath.Join(dir, ".potassium")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return cleanup(err)
+	}
+	log, err := openLog(filepath.Join(logDir, "server.log"), p.logMaxBytes, p.logMaxFiles)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	env := os.Environ()
+	for key, value := range req.Environment {
+		env = append(env, key+"="+value)
+	}
+	for _, binding := range req.Ports {
+		host := strconv.Itoa(ports[strconv.Itoa(binding.Container)])
+		env = append(env, "PORT_"+strconv.Itoa(binding.Container)+"="+host)
+		if binding.Name != "" {
+			env = append(env, "PORT_"+envName(binding.Name)+"="+host)
+		}
+	}
+
+	return &server{
+		info: orchestrator.Server{
+			ID:          id,
+			Name:        name,
+			Status:      orchestrator.StatusStopped,
+			IP:          p.hostIP,
+			Ports:       ports,
+			CPULimit:    req.CPULimit,
+			MemoryLimit: req.MemoryLimit,
+			MemorySwap:  req.MemorySwap,
+			PidsLimit:   req.PidsLimit,
+			Labels:      orchestrator.ServerLabels(req),
+		},
+		req:     req,
+		dir:     dir,
+		workDir: workDir,
+		argv:    argv,
+		env:     env,
+		log:     log,
+		created: time.Now(),
+	}, nil
+}
+
+// unsupported rejects request fields a plain process can't honor, so
+// a spec written for Docker fails loudly instead of running unisolated.
+func unsupported(req orchestrator.AllocateRequest) error {
+	var fields []string
+	check := func(set bool, field string) {
+		if set {
+			fields = append(fields, field)
+		}
+	}
+	check(req.Network != "", "network")
+	check(req.User != "", "user")
+	check(len(req.Ulimits) > 0, "ulimits")
+	check(len(req.Sysctls) > 0, "sysctls")
+	check(len(req.Tmpfs) > 0, "tmpfs")
+	check(req.ReadOnlyRootfs, "read_only_rootfs")
+	check(len(req.CapAdd) > 0 || len(req.CapDrop) > 0, "cap_add/cap_drop")
+	check(len(req.SecurityOpt) > 0, "security_opt")
+	check(len(req.DNS) > 0, "dns")
+	check(len(req.ExtraHosts) > 0, "extra_hosts")
+	check(req.DiskIOReadBps > 0 || req.DiskIOWriteBps > 0, "disk_io_read_bps/disk_io_write_bps")
+	check(req.DiskSizeLimit > 0, "disk_size_limit")
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fmt.Errorf("process provider does not support %s", strings.Join(fields, ", "))
+}
+
+// assignPorts resolves host ports for every binding. Callers must hold
+// p.mu.
+func (p *Provider) assignPorts(req orchestrator.AllocateRequest) (map[string]int, error) {
+	ports := map[string]int{}
+	for _, binding := range req.Ports {
+		host := binding.Host
+		switch {
+		case binding.Range != "":
+			low, high, err := orchestrator.ParsePortRange(binding.Range)
+			if err == nil {
+				host, err = p.ports.Reserve(binding.Protocol, low, high, func(port int) bool {
+					return !orchestrator.PortFree(binding.Protocol, port)
+				})
+			}
+			if err != nil {
+				p.releasePortsLocked(req, ports)
+				return nil, err
+			}
+		case host == 0:
+			host = binding.Container
+		}
+		ports[strconv.Itoa(binding.Container)] = host
+	}
+	return ports, nil
+}
+
+// releasePortsLocked returns ports reserved from ranges. Callers must
+// hold p.mu.
+func (p *Provider) releasePortsLocked(req orchestrator.AllocateRequest, ports map[string]int) {
+	for _, binding := range req.Ports {
+		if host, ok := ports[strconv.Itoa(binding.Container)]; ok && binding.Range != "" {
+			p.ports.Release(binding.Protocol, host)
+		}
+	}
+}
+
+func (p *Provider) Deallocate(ctx context.Context, id string) error {
+	return p.DeallocateWithOptions(ctx, id, orchestrator.StopOptions{})
+}
+
+// DeallocateWithOptions stops the server and deletes its directory.
+// Volume symlinks are removed, not the host directories they point to.
+func (p *Provider) DeallocateWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	s, err := p.server(id)
+	if err != nil {
+		return err
+	}
+	if err := p.stop(ctx, s, opts); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if s.removed {
+		p.mu.Unlock()
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	s.removed = true
+	delete(p.servers, id)
+	p.releasePortsLocked(s.req, s.info.Ports)
+	p.emit(s, orchestrator.EventDestroy)
+	p.mu.Unlock()
+
+	s.log.close()
+	return os.RemoveAll(s.dir)
+}
+
+func (p *Provider) Restart(ctx context.Context, id string) error {
+	return p.RestartWithOptions(ctx, id, orchestrator.StopOptions{})
+}
+
+// RestartWithOptions stops the server if it is running and starts it
+// again, resetting the restart policy's retry count.
+func (p *Provider) RestartWithOptions(ctx context.Context, id string, opts orchestrator.StopOptions) error {
+	s, err := p.server(id)
+	if err != nil {
+		return err
+	}
+	if err := p.stop(ctx, s, opts); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s.removed {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if s.proc != nil {
+		// Restarted concurrently
+		return nil
+	}
+	s.retries = 0
+	if err := p.start(s); err != nil {
+		return err
+	}
+	p.emit(s, orchestrator.EventRestart)
+	return nil
+}
+
+// Pause stops every process in the server with SIGSTOP.
+func (p *Provider) Pause(ctx context.Context, id string) error {
+	return p.setPaused(id, true)
+}
+
+// Resume continues a paused server with SIGCONT.
+func (p *Provider) Resume(ctx context.Context, id string) error {
+	return p.setPaused(id, false)
+}
+
+func (p *Provider) setPaused(id string, pause bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+
+	from, to, action, signal := orchestrator.StatusRunning, orchestrator.StatusPaused, orchestrator.EventPause, freezeGroup
+	if !pause {
+		from, to, action, signal = orchestrator.StatusPaused, orchestrator.StatusRunning, orchestrator.EventUnpause, thawGroup
+	}
+	if s.proc == nil || s.info.Status != from {
+		return fmt.Errorf("server %s is %s: %w", id, s.info.Status, orchestrator.ErrNotRunning)
+	}
+	if err := signal(s.proc.Process.Pid); err != nil {
+		return err
+	}
+	s.info.Status = to
+	p.emit(s, action)
+	return nil
+}
+
+// UpdateResources records the new limits on the server. Processes run
+// without resource isolation, so the limits are not enforced.
+func (p *Provider) UpdateResources(ctx context.Context, id string, update orchestrator.ResourceUpdate) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if update.MemoryLimit > 0 {
+		s.info.MemoryLimit = update.MemoryLimit
+	}
+	if update.MemorySwap != 0 {
+		s.info.MemorySwap = update.MemorySwap
+	}
+	if update.CPULimit > 0 {
+		s.info.CPULimit = update.CPULimit
+	}
+	if update.PidsLimit > 0 {
+		s.info.PidsLimit = update.PidsLimit
+	}
+	return nil
+}
+
+// SendCommand writes a line to the server's stdin, its console.
+func (p *Provider) SendCommand(ctx context.Context, id, command string) error {
+	p.mu.Lock()
+	s, ok := p.servers[id]
+	if !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	if s.proc == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("server %s: %w", id, orchestrator.ErrNotRunning)
+	}
+	stdin := s.stdin
+	p.mu.Unlock()
+
+	if !strings.HasSuffix(command, "\n") {
+		command += "\n"
+	}
+	if _, err := io.WriteString(stdin, command); err != nil {
+		return fmt.Errorf("console write failed: %w", err)
+	}
+	return nil
+}
+
+// Events subscribes to lifecycle events. Each subscriber has a buffer
+// of 64 events; events are dropped for subscribers that fall further
+// behind.
+func (p *Provider) Events(ctx context.Context) (<-chan orchestrator.ContainerEvent, <-chan error) {
+	eventCh := make(chan orchestrator.ContainerEvent, 64)
+	errCh := make(chan error, 1)
+
+	p.mu.Lock()
+	p.subscribers[eventCh] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		delete(p.subscribers, eventCh)
+		close(eventCh)
+		close(errCh)
+		p.mu.Unlock()
+	}()
+
+	return eventCh, errCh
+}
+
+// Close stops every server with the default stop options. Server
+// directories are kept.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	servers := make([]*server, 0, len(p.servers))
+	for _, s := range p.servers {
+		servers = append(servers, s)
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, s := range servers {
+		if err := p.stop(context.Background(), s, orchestrator.StopOptions{}); err != nil {
+			errs = append(errs, err)
+		}
+		s.log.close()
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Provider) server(id string) (*server, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[id]
+	if !ok {
+		return nil, fmt.Errorf("server %s: %w", id, orchestrator.ErrNotFound)
+	}
+	return s, nil
+}
+
+// emit fans an event out to subscribers. Callers must hold p.mu.
+func (p *Provider) emit(s *server, action string) {
+	p.emitEvent(s, orchestrator.ContainerEvent{Action: action})
+}
+
+// emitEvent fills in the server and time on event and fans it out.
+// Callers must hold p.mu.
+func (p *Provider) emitEvent(s *server, event orchestrator.ContainerEvent) {
+	now := time.Now()
+	event.ContainerID = s.info.ID
+	event.Name = s.info.Name
+	event.Time = now.Unix()
+	event.TimeNano = now.UnixNano()
+	for ch := range p.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// inDir maps a container path onto the server directory.
+func inDir(dir, containerPath string) string {
+	return filepath.Join(dir, filepath.FromSlash(filepath.Clean("/"+containerPath)))
+}
+
+// envName turns a port name like "query-udp" into QUERY_UDP.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// lookPath resolves the executable relative to the server's working
+// directory as well as PATH, so "./start.sh" works.
+func lookPath(name, workDir string) string {
+	if strings.Contains(name, string(filepath.Separator)) && !filepath.IsAbs(name) {
+		return filepath.Join(workDir, name)
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	return name
+}
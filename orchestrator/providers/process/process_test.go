@@ -0,0 +1,308 @@
+//go:build unix
+
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+	p, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func logsContain(p *Provider, id, text string) func() bool {
+	return func() bool {
+		logs, _ := p.Logs(context.Background(), id, 0)
+		return strings.Contains(logs, text)
+	}
+}
+
+// console echoes each stdin line and exits on "stop".
+const console = `echo "port $PORT_25565 query $PORT_QUERY"; echo warn >&2
+while read line; do
+	[ "$line" = stop ] && exit 0
+	echo "got $line"
+done`
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t)
+	events, _ := p.Events(t.Context())
+
+	world := t.TempDir()
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Name:       "lobby-1",
+		Command:    []string{"sh", "-c", console},
+		WorkingDir: "/game",
+		Volumes:    map[string]string{world: "/game/world"},
+		Ports: []orchestrator.PortBinding{
+			{Container: 25565, Range: "40000-40100", Protocol: "tcp"},
+			{Container: 25575, Host: 45575, Name: "query"},
+		},
+		Labels: map[string]string{"mode": "lobby"},
+	})
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	if server.Status != orchestrator.StatusRunning || server.IP != "127.0.0.1" {
+		t.Errorf("server = %+v", server)
+	}
+	port := server.Ports["25565"]
+	if port < 40000 || port > 40100 {
+		t.Errorf("ranged port = %d", port)
+	}
+
+	waitFor(t, "startup output", logsContain(p, server.ID, "query 45575"))
+	if logs, _ := p.Logs(ctx, server.ID, 0); !strings.Contains(logs, "warn") {
+		t.Errorf("stderr not captured: %q", logs)
+	}
+
+	if err := p.SendCommand(ctx, server.ID, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "console echo", logsContain(p, server.ID, "got hello"))
+
+	out, err := p.Exec(ctx, server.ID, []string{"sh", "-c", "pwd; echo saved > world/level.dat"})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out), filepath.Join(server.ID, "game")) {
+		t.Errorf("exec ran in %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(world, "level.dat")); err != nil {
+		t.Errorf("volume not linked into server dir: %v", err)
+	}
+
+	result, err := p.ExecWithOptions(ctx, server.ID, []string{"sh", "-c", "cat; echo $EXTRA >&2; exit 3"}, orchestrator.ExecOptions{
+		Env:   map[string]string{"EXTRA": "x"},
+		Stdin: []byte("input"),
+	})
+	if err != nil || result.ExitCode != 3 || result.Stdout != "input" || result.Stderr != "x\n" {
+		t.Errorf("ExecWithOptions = %+v, %v", result, err)
+	}
+
+	if list, _ := p.List(ctx, map[string]string{"mode": "lobby"}); len(list) != 1 {
+		t.Errorf("List = %d servers, want 1", len(list))
+	}
+	if _, err := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "lobby-1", Command: []string{"true"}}); !errors.Is(err, orchestrator.ErrAlreadyExists) {
+		t.Errorf("duplicate name error = %v", err)
+	}
+
+	err = p.DeallocateWithOptions(ctx, server.ID, orchestrator.StopOptions{PreStopCommand: "stop"})
+	if err != nil {
+		t.Fatalf("Deallocate: %v", err)
+	}
+	if _, err := p.Get(ctx, server.ID); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Errorf("Get after Deallocate = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(p.root, server.ID)); !os.IsNotExist(err) {
+		t.Error("server directory not removed")
+	}
+	if _, err := os.Stat(world); err != nil {
+		t.Error("volume source removed with server")
+	}
+
+	var actions []string
+	for len(events) > 0 {
+		e := <-events
+		if e.ContainerID == server.ID {
+			actions = append(actions, e.Action)
+		}
+	}
+	want := "create start die stop destroy"
+	if got := strings.Join(actions, " "); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestStopEscalates(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t)
+
+	// Ignores SIGTERM, so stopping needs the kill after the grace period
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Command: []string{"sh", "-c", "trap '' TERM; echo up; while :; do sleep 0.05; done"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "startup", logsContain(p, server.ID, "up"))
+
+	events, _ := p.Events(t.Context())
+	start := time.Now()
+	if err := p.RestartWithOptions(ctx, server.ID, orchestrator.StopOptions{GracePeriodSeconds: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("restart took %s; SIGTERM should have been ignored", elapsed)
+	}
+
+	die := <-events
+	if die.Action != orchestrator.EventDie || die.ExitCode != 137 {
+		t.Errorf("first event = %s exit %d, want die exit 137", die.Action, die.ExitCode)
+	}
+	s, _ := p.Get(ctx, server.ID)
+	if s.Status != orchestrator.StatusRunning {
+		t.Errorf("status after restart = %s", s.Status)
+	}
+
+	if err := p.DeallocateWithOptions(ctx, server.ID, orchestrator.StopOptions{Signal: "KILL"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t)
+	events, _ := p.Events(t.Context())
+
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Command:           []string{"sh", "-c", "exit 2"},
+		RestartPolicy:     orchestrator.RestartOnFailure,
+		RestartMaxRetries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dies := 0
+	timeout := time.After(5 * time.Second)
+	for dies < 3 {
+		select {
+		case e := <-events:
+			if e.Action == orchestrator.EventDie {
+				dies++
+				if e.ExitCode != 2 {
+					t.Errorf("exit code = %d, want 2", e.ExitCode)
+				}
+			}
+		case <-timeout:
+			t.Fatalf("saw %d exits, want 3 (first run plus 2 retries)", dies)
+		}
+	}
+
+	// No further retries
+	time.Sleep(500 * time.Millisecond)
+	s, _ := p.Get(ctx, server.ID)
+	if s.Status != orchestrator.StatusStopped {
+		t.Errorf("status = %s, want stopped", s.Status)
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Action == orchestrator.EventStart {
+			t.Error("restarted beyond max retries")
+		}
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t)
+
+	server, err := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Command: []string{"sh", "-c", "while :; do sleep 0.05; done"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Pause(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := p.Get(ctx, server.ID); s.Status != orchestrator.StatusPaused {
+		t.Errorf("status = %s, want paused", s.Status)
+	}
+	if _, err := p.Exec(ctx, server.ID, []string{"true"}); !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("exec in paused server = %v", err)
+	}
+	if err := p.Pause(ctx, server.ID); !errors.Is(err, orchestrator.ErrNotRunning) {
+		t.Errorf("second Pause = %v", err)
+	}
+	if err := p.Resume(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A paused server can still be stopped
+	p.Pause(ctx, server.ID)
+	if err := p.DeallocateWithOptions(ctx, server.ID, orchestrator.StopOptions{GracePeriodSeconds: 2}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllocateRejects(t *testing.T) {
+	p := newTestProvider(t)
+
+	_, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{
+		Command: []string{"true"},
+		Network: "banananet",
+		CapAdd:  []string{"NET_ADMIN"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "network, cap_add/cap_drop") {
+		t.Errorf("err = %v", err)
+	}
+
+	if _, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{}); err == nil {
+		t.Error("allocated without a command")
+	}
+
+	_, err = p.Allocate(context.Background(), orchestrator.AllocateRequest{Command: []string{"/nonexistent/server"}})
+	if err == nil {
+		t.Error("allocated a missing executable")
+	}
+	if list, _ := p.List(context.Background(), nil); len(list) != 0 {
+		t.Errorf("failed allocation left %d servers", len(list))
+	}
+}
+
+func TestAllocateNameRace(t *testing.T) {
+	p := newTestProvider(t)
+
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			_, err := p.Allocate(context.Background(), orchestrator.AllocateRequest{
+				Name:    "lobby-1",
+				Command: []string{"sleep", "60"},
+			})
+			errs <- err
+		}()
+	}
+	created := 0
+	for range cap(errs) {
+		err := <-errs
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, orchestrator.ErrAlreadyExists):
+			t.Errorf("Allocate = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d servers named lobby-1 created, want 1", created)
+	}
+}
@@ -0,0 +1,238 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// Restart backoff, as in Docker: the delay doubles after each restart
+// and resets once a run stays up for restartResetAfter.
+const (
+	restartDelayMin   = 100 * time.Millisecond
+	restartDelayMax   = time.Minute
+	restartResetAfter = 10 * time.Second
+
+	// defaultGracePeriod applies when StopOptions has none, as with
+	// Docker's default stop timeout.
+	defaultGracePeriod = 10 * time.Second
+
+	// outputWaitDelay bounds how long output is collected after the
+	// server's process exits, in case a child still holds it open.
+	outputWaitDelay = 2 * time.Second
+)
+
+// server is one allocated server. Fields below created are guarded by
+// Provider.mu.
+type server struct {
+	info    orchestrator.Server
+	req     orchestrator.AllocateRequest
+	dir     string
+	workDir string
+	argv    []string
+	env     []string
+	log     *logFile
+	created time.Time
+
+	proc     *exec.Cmd      // current run; nil when not running
+	stdin    io.WriteCloser // console of the current run
+	exited   chan struct{}  // closed when the current run ends
+	started  time.Time
+	stopping bool   // the current run is being stopped deliberately
+	gen      uint64 // bumped on every start or stop to cancel pending restarts
+	retries  int
+	removed  bool
+}
+
+func (s *server) snapshot() orchestrator.Server {
+	info := s.info
+	info.Ports = make(map[string]int, len(s.info.Ports))
+	for k, v := range s.info.Ports {
+		info.Ports[k] = v
+	}
+	info.Labels = make(map[string]string, len(s.info.Labels))
+	for k, v := range s.info.Labels {
+		info.Labels[k] = v
+	}
+	return info
+}
+
+// start launches a run of the server's command. Callers must hold p.mu.
+func (p *Provider) start(s *server) error {
+	cmd := exec.Command(lookPath(s.argv[0], s.workDir), s.argv[1:]...)
+	cmd.Dir = s.workDir
+	cmd.Env = s.env
+	cmd.SysProcAttr = sysProcAttr()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// Copy output through in-memory pipes rather than os pipes so Wait
+	// doesn't hang on a grandchild that inherited stdout
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	cmd.WaitDelay = outputWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		s.info.Status = orchestrator.StatusError
+		s.log.write(orchestrator.LogLine{Time: time.Now(), Stream: orchestrator.StreamStderr, Text: err.Error()})
+		return fmt.Errorf("start %s: %w", s.argv[0], err)
+	}
+
+	s.proc = cmd
+	s.stdin = stdin
+	s.exited = make(chan struct{})
+	s.started = time.Now()
+	s.stopping = false
+	s.gen++
+	s.info.Status = orchestrator.StatusRunning
+	p.emit(s, orchestrator.EventStart)
+
+	var output sync.WaitGroup
+	output.Add(2)
+	go func() {
+		defer output.Done()
+		s.log.pump(stdout, orchestrator.StreamStdout)
+	}()
+	go func() {
+		defer output.Done()
+		s.log.pump(stderr, orchestrator.StreamStderr)
+	}()
+	go func() {
+		cmd.Wait()
+		stdoutW.Close()
+		stderrW.Close()
+		output.Wait()
+		p.exited(s, exitCode(cmd.ProcessState))
+	}()
+	return nil
+}
+
+// exited records how a run ended and applies the restart policy.
+func (p *Provider) exited(s *server, code int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stopping := s.stopping
+	ranFor := time.Since(s.started)
+	s.proc = nil
+	s.stdin = nil
+	s.stopping = false
+	s.info.Status = orchestrator.StatusStopped
+	close(s.exited)
+	s.log.endFollowers()
+	p.emitEvent(s, orchestrator.ContainerEvent{Action: orchestrator.EventDie, ExitCode: code})
+
+	if stopping || s.removed || p.closed || !shouldRestart(s.req, code, s.retries) {
+		return
+	}
+	if ranFor >= restartResetAfter {
+		s.retries = 0
+	}
+	delay := min(restartDelayMin<<min(s.retries, 10), restartDelayMax)
+	s.retries++
+
+	gen := s.gen
+	time.AfterFunc(delay, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if s.gen != gen || s.proc != nil || s.removed || p.closed {
+			return
+		}
+		p.start(s)
+	})
+}
+
+// shouldRestart applies the request's restart policy to a run that
+// ended on its own.
+func shouldRestart(req orchestrator.AllocateRequest, exitCode, retries int) bool {
+	switch req.RestartPolicy {
+	case orchestrator.RestartAlways, orchestrator.RestartUnlessStopped:
+		return true
+	case orchestrator.RestartOnFailure:
+		return exitCode != 0 && (req.RestartMaxRetries == 0 || retries < req.RestartMaxRetries)
+	}
+	return false
+}
+
+// stop ends the current run, if any, and cancels pending restarts. A
+// PreStopCommand is written to the console first and the server given
+// PreStopTimeoutSeconds to exit on its own; then the stop signal is sent
+// to its process group and, after the grace period, SIGKILL.
+func (p *Provider) stop(ctx context.Context, s *server, opts orchestrator.StopOptions) error {
+	sig, err := parseSignal(opts.Signal)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	s.gen++
+	if s.proc == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	s.stopping = true
+	pid := s.proc.Process.Pid
+	paused := s.info.Status == orchestrator.StatusPaused
+	exited := s.exited
+	id := s.info.ID
+	p.mu.Unlock()
+
+	wait := func(d time.Duration) bool {
+		select {
+		case <-exited:
+			return true
+		case <-time.After(d):
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if opts.PreStopCommand != "" && !paused {
+		if p.SendCommand(ctx, id, opts.PreStopCommand) == nil {
+			timeout := opts.PreStopTimeoutSeconds
+			if timeout <= 0 {
+				timeout = orchestrator.DefaultPreStopTimeoutSeconds
+			}
+			if wait(time.Duration(timeout) * time.Second) {
+				p.stopped(s)
+				return nil
+			}
+		}
+	}
+
+	grace := defaultGracePeriod
+	if opts.GracePeriodSeconds > 0 {
+		grace = time.Duration(opts.GracePeriodSeconds) * time.Second
+	}
+	signalGroup(pid, sig)
+	if paused {
+		// A stopped process only handles the signal once continued
+		thawGroup(pid)
+	}
+	if !wait(grace) {
+		if ctx.Err() != nil {
+			killGroup(pid)
+			return ctx.Err()
+		}
+		killGroup(pid)
+		<-exited
+	}
+	p.stopped(s)
+	return nil
+}
+
+func (p *Provider) stopped(s *server) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit(s, orchestrator.EventStop)
+}
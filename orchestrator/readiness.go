@@ -0,0 +1,263 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ReadinessSpec describes when a freshly started server is ready to
+// accept players. Every condition that is set must pass. With Wait set,
+// Allocate blocks until the server is ready or the timeout expires.
+type ReadinessSpec struct {
+	HealthCheck     *HealthCheck `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	Port            *PortProbe   `json:"port,omitempty" yaml:"port,omitempty"`
+	LogPattern      string       `json:"log_pattern,omitempty" yaml:"log_pattern,omitempty"` // e.g. "Server started"
+	TimeoutSeconds  int          `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+	IntervalSeconds int          `json:"interval_seconds,omitempty" yaml:"interval_seconds,omitempty"`
+	Wait            bool         `json:"wait,omitempty" yaml:"wait,omitempty"`
+}
+
+// HealthCheck waits for the runtime's health status to become healthy.
+// An empty Test relies on the HEALTHCHECK baked into the image.
+type HealthCheck struct {
+	Test               []string `json:"test,omitempty" yaml:"test,omitempty"` // e.g. ["CMD-SHELL", "nc -z localhost 25565"]
+	IntervalSeconds    int      `json:"interval_seconds,omitempty" yaml:"interval_seconds,omitempty"`
+	TimeoutSeconds     int      `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+	StartPeriodSeconds int      `json:"start_period_seconds,omitempty" yaml:"start_period_seconds,omitempty"`
+	Retries            int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+// PortProbe checks that a container port accepts traffic. The probe
+// targets the published host port on Host (default 127.0.0.1) when the
+// port is bound, otherwise the server's IP and container port.
+type PortProbe struct {
+	Port     int    `json:"port" yaml:"port"`
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"` // tcp (default) or udp
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+}
+
+// Health values reported by HealthChecker.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthNone      = "none"
+)
+
+// HealthChecker is implemented by providers that expose a runtime health
+// status (e.g. Docker HEALTHCHECK). Required for ReadinessSpec.HealthCheck.
+type HealthChecker interface {
+	Health(ctx context.Context, id string) (string, error)
+}
+
+// ReadinessError is returned when a server fails to become ready. It
+// carries the tail of the server's output to explain why.
+type ReadinessError struct {
+	ServerID string
+	Reason   string // timeout, exited, unhealthy, logs
+	LastLogs string
+	Err      error
+}
+
+func (e *ReadinessError) Error() string {
+	msg := fmt.Sprintf("server %s not ready: %s", e.ServerID, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ReadinessError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	defaultReadyTimeout  = 120 * time.Second
+	defaultReadyInterval = time.Second
+	readyLogTail         = 50
+)
+
+// WaitReady blocks until the server satisfies spec, the spec's timeout
+// expires or ctx is cancelled. Failures are reported as *ReadinessError.
+func WaitReady(ctx context.Context, p Provider, id string, spec ReadinessSpec) error {
+	timeout := defaultReadyTimeout
+	if spec.TimeoutSeconds > 0 {
+		timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	interval := defaultReadyInterval
+	if spec.IntervalSeconds > 0 {
+		interval = time.Duration(spec.IntervalSeconds) * time.Second
+	}
+
+	var pattern *regexp.Regexp
+	if spec.LogPattern != "" {
+		var err error
+		pattern, err = regexp.Compile(spec.LogPattern)
+		if err != nil {
+			return fmt.Errorf("invalid log pattern: %w", err)
+		}
+	}
+
+	var checker HealthChecker
+	if spec.HealthCheck != nil {
+		var ok bool
+		if checker, ok = p.(HealthChecker); !ok {
+			return fmt.Errorf("provider %T does not support health checks", p)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fail := func(reason string, err error) error {
+		// Use a fresh context: waitCtx may be the reason we're failing
+		logCtx, logCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer logCancel()
+		logs, _ := p.Logs(logCtx, id, readyLogTail)
+		return &ReadinessError{ServerID: id, Reason: reason, LastLogs: logs, Err: err}
+	}
+
+	// The log pattern is matched by a follower running alongside the
+	// polling loop; it replays history so early lines aren't missed.
+	// If the stream fails before a match the pattern can never pass.
+	logMatched := make(chan struct{})
+	logFailed := make(chan error, 1)
+	if pattern != nil {
+		lines, errs := p.StreamLogs(waitCtx, id, LogOptions{Follow: true})
+		matched := logMatched
+		go func() {
+			for line := range lines {
+				if pattern.MatchString(line.Text) {
+					close(matched)
+					for range lines {
+					}
+					return
+				}
+			}
+			if err := <-errs; err != nil {
+				logFailed <- err
+			}
+		}()
+	}
+
+	logReady := pattern == nil
+	portReady := spec.Port == nil
+	healthReady := checker == nil
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		server, err := p.Get(waitCtx, id)
+		if err != nil {
+			if waitCtx.Err() != nil {
+				return fail("timeout", waitCtx.Err())
+			}
+			return fail("exited", err)
+		}
+		if server.Status != StatusRunning {
+			return fail("exited", fmt.Errorf("status %s", server.Status))
+		}
+
+		if !logReady {
+			select {
+			case <-logMatched:
+				logReady = true
+				logMatched = nil // stop waking the select below
+			default:
+			}
+		}
+
+		if !portReady {
+			portReady = probePort(waitCtx, server, *spec.Port, interval)
+		}
+
+		if !healthReady {
+			status, err := checker.Health(waitCtx, id)
+			if err != nil && waitCtx.Err() == nil {
+				return fail("unhealthy", err)
+			}
+			switch status {
+			case HealthHealthy:
+				healthReady = true
+			case HealthUnhealthy:
+				return fail("unhealthy", nil)
+			case HealthNone:
+				return fail("unhealthy", errors.New("server has no health check"))
+			}
+		}
+
+		if logReady && portReady && healthReady {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-logMatched:
+		case err := <-logFailed:
+			if waitCtx.Err() != nil {
+				return fail("timeout", waitCtx.Err())
+			}
+			return fail("logs", err)
+		case <-waitCtx.Done():
+			return fail("timeout", waitCtx.Err())
+		}
+	}
+}
+
+// probePort reports whether the probe target accepts traffic. TCP needs
+// a completed handshake. UDP is connectionless, so the best available
+// signal is the absence of an ICMP port-unreachable reply to an empty
+// datagram: a refused read means closed, silence means open.
+func probePort(ctx context.Context, server *Server, probe PortProbe, timeout time.Duration) bool {
+	host := probe.Host
+	port := probe.Port
+	if hostPort, ok := server.Ports[strconv.Itoa(probe.Port)]; ok && hostPort > 0 {
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		port = hostPort
+	} else if host == "" {
+		host = server.IP
+	}
+	if host == "" {
+		return false
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: timeout}
+	if normalizeProtocol(probe.Protocol) != "udp" {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return false
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout / 2))
+	_, err = conn.Read(make([]byte, 1))
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,133 @@
+package orchestrator_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+)
+
+func TestWaitReady_LogPattern(t *testing.T) {
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		p.AppendLogs(server.ID, "Loading world...", "Server started on port 25565")
+	}()
+
+	err := orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		LogPattern:     `Server started`,
+		TimeoutSeconds: 5,
+	})
+	if err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+}
+
+func TestWaitReady_ExitedIncludesLogs(t *testing.T) {
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	p.AppendLogs(server.ID, "java.lang.OutOfMemoryError")
+	p.Crash(server.ID)
+
+	err := orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		LogPattern:     `Server started`,
+		TimeoutSeconds: 5,
+	})
+
+	var readyErr *orchestrator.ReadinessError
+	if !errors.As(err, &readyErr) {
+		t.Fatalf("err = %v, want *ReadinessError", err)
+	}
+	if readyErr.Reason != "exited" {
+		t.Errorf("Reason = %q, want exited", readyErr.Reason)
+	}
+	if !strings.Contains(readyErr.LastLogs, "OutOfMemoryError") {
+		t.Errorf("LastLogs = %q, want crash output", readyErr.LastLogs)
+	}
+}
+
+func TestWaitReady_Timeout(t *testing.T) {
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	err := orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		LogPattern:     `never`,
+		TimeoutSeconds: 1,
+	})
+
+	var readyErr *orchestrator.ReadinessError
+	if !errors.As(err, &readyErr) || readyErr.Reason != "timeout" {
+		t.Fatalf("err = %v, want timeout ReadinessError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want it to wrap DeadlineExceeded", err)
+	}
+}
+
+func TestWaitReady_LogStreamFails(t *testing.T) {
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	streamErr := errors.New("log driver does not support reading")
+	p.FailNext(orchestratortest.OpStreamLogs, streamErr)
+	err := orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		LogPattern:     `Server started`,
+		TimeoutSeconds: 5,
+	})
+
+	var readyErr *orchestrator.ReadinessError
+	if !errors.As(err, &readyErr) || readyErr.Reason != "logs" {
+		t.Fatalf("err = %v, want logs ReadinessError", err)
+	}
+	if !errors.Is(err, streamErr) {
+		t.Errorf("err = %v, want the stream error", err)
+	}
+}
+
+func TestWaitReady_TCPPortProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	hostPort := ln.Addr().(*net.TCPAddr).Port
+
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{
+		Ports: []orchestrator.PortBinding{{Host: hostPort, Container: 25565, Protocol: "tcp"}},
+	})
+
+	err = orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		Port:           &orchestrator.PortProbe{Port: 25565},
+		TimeoutSeconds: 5,
+	})
+	if err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+}
+
+func TestWaitReady_HealthCheckUnsupported(t *testing.T) {
+	p := orchestratortest.New()
+	ctx := context.Background()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	err := orchestrator.WaitReady(ctx, p, server.ID, orchestrator.ReadinessSpec{
+		HealthCheck: &orchestrator.HealthCheck{},
+	})
+	if err == nil {
+		t.Fatal("expected error for provider without health checks")
+	}
+}
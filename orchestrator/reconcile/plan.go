@@ -0,0 +1,241 @@
+package reconcile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// ActionType is what an Action does to a server.
+type ActionType string
+
+const (
+	ActionCreate   ActionType = "create"
+	ActionRemove   ActionType = "remove"
+	ActionRecreate ActionType = "recreate" // remove, then create with the same name
+)
+
+// Action is one step of a Plan.
+type Action struct {
+	Type ActionType `json:"type"`
+	Spec string     `json:"spec"`
+	// Name of the server removed or created.
+	Name string `json:"name"`
+	// ServerID of the existing server; empty for creates.
+	ServerID string `json:"server_id,omitempty"`
+	// Reason is a short human-readable cause, e.g. "spec changed" or
+	// "memory limit 1073741824, want 2147483648".
+	Reason string `json:"reason"`
+	// Request is what will be allocated; nil for removes.
+	Request *orchestrator.AllocateRequest `json:"request,omitempty"`
+}
+
+func (a Action) String() string {
+	symbol := map[ActionType]string{ActionCreate: "+", ActionRemove: "-", ActionRecreate: "~"}[a.Type]
+	s := fmt.Sprintf("%s %s %s/%s", symbol, a.Type, a.Spec, a.Name)
+	if a.ServerID != "" {
+		s += " (" + a.ServerID + ")"
+	}
+	return s + ": " + a.Reason
+}
+
+// Plan is the set of actions that would bring the provider to the
+// desired state: removals of unknown specs first, then scale-downs,
+// creates and recreates, grouped by spec.
+type Plan struct {
+	Actions []Action `json:"actions"`
+}
+
+// Empty reports whether the provider already matches the desired state.
+func (p Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// String renders the plan one action per line, for dry runs.
+func (p Plan) String() string {
+	if p.Empty() {
+		return "no changes"
+	}
+	lines := make([]string, len(p.Actions))
+	for i, a := range p.Actions {
+		lines[i] = a.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Hash fingerprints a request so drift can be detected from a label.
+// Name and the reconciler's own labels are ignored.
+func Hash(req orchestrator.AllocateRequest) string {
+	req.Name = ""
+	if req.Labels != nil {
+		labels := make(map[string]string, len(req.Labels))
+		for k, v := range req.Labels {
+			if k != LabelSpec && k != LabelSpecHash {
+				labels[k] = v
+			}
+		}
+		req.Labels = labels
+	}
+	// Maps marshal with sorted keys, so equal requests hash equally
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// plan diffs the desired specs against the servers that carry
+// LabelSpec.
+func plan(specs []Spec, servers []orchestrator.Server) Plan {
+	bySpec := map[string][]orchestrator.Server{}
+	for _, s := range servers {
+		name := s.Labels[LabelSpec]
+		bySpec[name] = append(bySpec[name], s)
+	}
+
+	var p Plan
+	desired := map[string]bool{}
+	for _, spec := range specs {
+		desired[spec.Name] = true
+	}
+	var orphans []string
+	for name := range bySpec {
+		if !desired[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		for _, s := range sortByName(bySpec[name]) {
+			p.Actions = append(p.Actions, Action{
+				Type:     ActionRemove,
+				Spec:     name,
+				Name:     serverName(s),
+				ServerID: s.ID,
+				Reason:   "spec no longer desired",
+			})
+		}
+	}
+
+	for _, spec := range specs {
+		p.Actions = append(p.Actions, planSpec(spec, bySpec[spec.Name])...)
+	}
+	return p
+}
+
+func planSpec(spec Spec, servers []orchestrator.Server) []Action {
+	req := spec.request()
+	hash := req.Labels[LabelSpecHash]
+
+	type current struct {
+		server orchestrator.Server
+		drift  string
+	}
+	var clean, drifted []current
+	names := map[string]bool{}
+	for _, s := range sortByName(servers) {
+		names[serverName(s)] = true
+		c := current{server: s, drift: drift(s, req, hash)}
+		if c.drift == "" {
+			clean = append(clean, c)
+		} else {
+			drifted = append(drifted, c)
+		}
+	}
+
+	var actions []Action
+	// Scale down drifted servers first, then the highest names
+	keep := append(clean, drifted...)
+	if excess := len(keep) - spec.Replicas; excess > 0 {
+		for _, c := range keep[len(keep)-excess:] {
+			actions = append(actions, Action{
+				Type:     ActionRemove,
+				Spec:     spec.Name,
+				Name:     serverName(c.server),
+				ServerID: c.server.ID,
+				Reason:   fmt.Sprintf("scale down to %d", spec.Replicas),
+			})
+		}
+		keep = keep[:len(keep)-excess]
+	}
+
+	next := 1
+	for i := len(keep); i < spec.Replicas; i++ {
+		for names[spec.Name+"-"+strconv.Itoa(next)] {
+			next++
+		}
+		name := spec.Name + "-" + strconv.Itoa(next)
+		names[name] = true
+		create := req
+		create.Name = name
+		actions = append(actions, Action{
+			Type:    ActionCreate,
+			Spec:    spec.Name,
+			Name:    name,
+			Reason:  fmt.Sprintf("scale up to %d", spec.Replicas),
+			Request: &create,
+		})
+	}
+
+	for _, c := range keep {
+		if c.drift == "" {
+			continue
+		}
+		recreate := req
+		recreate.Name = serverName(c.server)
+		actions = append(actions, Action{
+			Type:     ActionRecreate,
+			Spec:     spec.Name,
+			Name:     recreate.Name,
+			ServerID: c.server.ID,
+			Reason:   c.drift,
+			Request:  &recreate,
+		})
+	}
+	return actions
+}
+
+// drift describes how s differs from req, or returns "" if it matches.
+// Swap and PIDs are only compared when the spec sets them, since
+// runtimes report their own defaults otherwise.
+func drift(s orchestrator.Server, req orchestrator.AllocateRequest, hash string) string {
+	var reasons []string
+	if got := s.Labels[LabelSpecHash]; got != hash {
+		reasons = append(reasons, "spec changed")
+	}
+	if s.MemoryLimit != req.MemoryLimit {
+		reasons = append(reasons, fmt.Sprintf("memory limit %d, want %d", s.MemoryLimit, req.MemoryLimit))
+	}
+	if math.Abs(s.CPULimit-req.CPULimit) > 1e-6 {
+		reasons = append(reasons, fmt.Sprintf("cpu limit %g, want %g", s.CPULimit, req.CPULimit))
+	}
+	if req.MemorySwap != 0 && s.MemorySwap != req.MemorySwap {
+		reasons = append(reasons, fmt.Sprintf("memory swap %d, want %d", s.MemorySwap, req.MemorySwap))
+	}
+	if req.PidsLimit != 0 && s.PidsLimit != req.PidsLimit {
+		reasons = append(reasons, fmt.Sprintf("pids limit %d, want %d", s.PidsLimit, req.PidsLimit))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// serverName strips the leading slash Docker puts on container names.
+func serverName(s orchestrator.Server) string {
+	return strings.TrimPrefix(s.Name, "/")
+}
+
+func sortByName(servers []orchestrator.Server) []orchestrator.Server {
+	sorted := append([]orchestrator.Server(nil), servers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := serverName(sorted[i]), serverName(sorted[j])
+		if len(a) != len(b) {
+			return len(a) < len(b) // lobby-9 before lobby-10
+		}
+		return a < b
+	})
+	return sorted
+}
@@ -0,0 +1,273 @@
+// Package reconcile keeps a provider's servers matching a declared set
+// of specs: it creates missing replicas, removes extra or undeclared
+// ones and recreates servers whose spec or limits have drifted.
+//
+//	r, err := reconcile.New(provider, []reconcile.Spec{
+//		{Name: "lobby", Replicas: 3, Request: lobbyReq},
+//		{Name: "game", Replicas: 10, Request: gameReq},
+//	}, reconcile.Config{MaxActions: 5})
+//	plan, _ := r.Plan(ctx)
+//	fmt.Println(plan) // what would change
+//	go r.Run(ctx)
+//
+// Only servers carrying LabelSpec are touched, so hand-allocated servers
+// on the same provider are left alone. Server status is not reconciled:
+// a stopped replica still counts, restarting it is up to its restart
+// policy or a supervisor.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+const (
+	// LabelSpec names the spec a server was created from.
+	LabelSpec = "potassium.spec"
+	// LabelSpecHash holds Hash of the spec's request at creation.
+	LabelSpecHash = "potassium.spec-hash"
+)
+
+// ErrDefer can be returned from Hooks.BeforeAction to skip an action
+// quietly; it is planned again on the next pass.
+var ErrDefer = errors.New("action deferred")
+
+// Spec declares Replicas identical servers. Replicas are named
+// "<Name>-<n>"; Request.Name is ignored.
+type Spec struct {
+	Name     string                       `json:"name" yaml:"name"`
+	Replicas int                          `json:"replicas" yaml:"replicas"`
+	Request  orchestrator.AllocateRequest `json:"request" yaml:"request"`
+}
+
+// request returns the spec's request with the reconciler's labels.
+func (s Spec) request() orchestrator.AllocateRequest {
+	req := s.Request
+	req.Name = ""
+	labels := make(map[string]string, len(req.Labels)+2)
+	for k, v := range req.Labels {
+		labels[k] = v
+	}
+	labels[LabelSpec] = s.Name
+	labels[LabelSpecHash] = Hash(s.Request)
+	req.Labels = labels
+	return req
+}
+
+// Hooks are called around each action Reconcile applies.
+type Hooks struct {
+	// BeforeAction can veto an action, e.g. to drain players before a
+	// server is removed. ErrDefer skips it silently; other errors are
+	// reported in Reconcile's error.
+	BeforeAction func(ctx context.Context, action Action) error
+	// AfterAction receives the outcome. server is the new server for
+	// creates and recreates, nil for removes and failures.
+	AfterAction func(ctx context.Context, action Action, server *orchestrator.Server, err error)
+}
+
+// Config controls a Reconciler.
+type Config struct {
+	// Interval between passes in Run. Default 30 seconds.
+	Interval time.Duration
+	// Selector scopes the reconciler to servers whose labels match, so
+	// several reconcilers can share a provider. Every spec's request
+	// labels must match it.
+	Selector map[string]string
+	// MaxActions caps the actions applied per pass; the rest wait for
+	// the next pass. Zero is unlimited.
+	MaxActions int
+	// MaxRecreatesPerSpec caps how many replicas of one spec are
+	// recreated per pass, so a spec change rolls through instead of
+	// replacing every replica at once. Default 1.
+	MaxRecreatesPerSpec int
+	// StopOptions are used when removing servers.
+	StopOptions orchestrator.StopOptions
+	// DryRun makes Reconcile and Run only plan; nothing is changed.
+	DryRun bool
+	Hooks  Hooks
+}
+
+const (
+	defaultInterval            = 30 * time.Second
+	defaultMaxRecreatesPerSpec = 1
+)
+
+// Result reports what one pass did.
+type Result struct {
+	Plan Plan
+	// Applied actions succeeded.
+	Applied []Action
+	// Deferred actions were held back by rate limits or a hook.
+	Deferred []Action
+}
+
+// Reconciler drives a provider towards the desired specs.
+type Reconciler struct {
+	provider orchestrator.Provider
+	cfg      Config
+
+	mu    sync.Mutex
+	specs []Spec
+
+	pass sync.Mutex // serializes passes
+}
+
+func New(provider orchestrator.Provider, specs []Spec, cfg Config) (*Reconciler, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.MaxRecreatesPerSpec <= 0 {
+		cfg.MaxRecreatesPerSpec = defaultMaxRecreatesPerSpec
+	}
+	r := &Reconciler{provider: provider, cfg: cfg}
+	if err := r.SetSpecs(specs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetSpecs replaces the desired state; the next pass acts on it.
+func (r *Reconciler) SetSpecs(specs []Spec) error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, s := range specs {
+		switch {
+		case s.Name == "":
+			errs = append(errs, errors.New("spec name required"))
+		case seen[s.Name]:
+			errs = append(errs, fmt.Errorf("spec %s declared twice", s.Name))
+		case s.Replicas < 0:
+			errs = append(errs, fmt.Errorf("spec %s: replicas must not be negative", s.Name))
+		case !orchestrator.MatchLabels(s.Request.Labels, r.cfg.Selector):
+			// Its servers would never be listed, so it would scale up forever
+			errs = append(errs, fmt.Errorf("spec %s: labels don't match the reconciler's selector", s.Name))
+		}
+		seen[s.Name] = true
+		if err := s.Request.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("spec %s: %w", s.Name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.specs = append([]Spec(nil), specs...)
+	r.mu.Unlock()
+	return nil
+}
+
+// Plan lists the actions a pass would take, ignoring rate limits.
+func (r *Reconciler) Plan(ctx context.Context) (Plan, error) {
+	filter := map[string]string{LabelSpec: ""}
+	for k, v := range r.cfg.Selector {
+		filter[k] = v
+	}
+	servers, err := r.provider.List(ctx, filter)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	r.mu.Lock()
+	specs := r.specs
+	r.mu.Unlock()
+	return plan(specs, servers), nil
+}
+
+// Reconcile runs one pass: it plans, then applies actions in order until
+// MaxActions is reached. Failed actions are joined into the error and
+// retried on the next pass.
+func (r *Reconciler) Reconcile(ctx context.Context) (Result, error) {
+	r.pass.Lock()
+	defer r.pass.Unlock()
+
+	p, err := r.Plan(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Plan: p}
+	if r.cfg.DryRun {
+		return result, nil
+	}
+
+	var errs []error
+	attempted := 0
+	recreates := map[string]int{}
+	for _, a := range p.Actions {
+		limited := r.cfg.MaxActions > 0 && attempted >= r.cfg.MaxActions
+		if a.Type == ActionRecreate && recreates[a.Spec] >= r.cfg.MaxRecreatesPerSpec {
+			limited = true
+		}
+		if limited || ctx.Err() != nil {
+			result.Deferred = append(result.Deferred, a)
+			continue
+		}
+
+		if hook := r.cfg.Hooks.BeforeAction; hook != nil {
+			if err := hook(ctx, a); err != nil {
+				result.Deferred = append(result.Deferred, a)
+				if !errors.Is(err, ErrDefer) {
+					errs = append(errs, fmt.Errorf("%s %s: %w", a.Type, a.Name, err))
+				}
+				continue
+			}
+		}
+
+		attempted++
+		if a.Type == ActionRecreate {
+			recreates[a.Spec]++
+		}
+		server, err := r.apply(ctx, a)
+		if hook := r.cfg.Hooks.AfterAction; hook != nil {
+			hook(ctx, a, server, err)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", a.Type, a.Name, err))
+			continue
+		}
+		result.Applied = append(result.Applied, a)
+	}
+	return result, errors.Join(errs...)
+}
+
+func (r *Reconciler) apply(ctx context.Context, a Action) (*orchestrator.Server, error) {
+	if a.Type == ActionRemove || a.Type == ActionRecreate {
+		err := r.provider.DeallocateWithOptions(ctx, a.ServerID, r.cfg.StopOptions)
+		if err != nil && !errors.Is(err, orchestrator.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if a.Type == ActionRemove {
+		return nil, nil
+	}
+	return r.provider.Allocate(ctx, *a.Request)
+}
+
+// Run reconciles every Interval until ctx is cancelled. Errors from a
+// pass are logged and the next pass retries; in DryRun non-empty plans
+// are logged instead of applied.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := r.Reconcile(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("reconcile: %v", err)
+		}
+		if r.cfg.DryRun && !result.Plan.Empty() {
+			log.Printf("reconcile: dry run plan:\n%s", result.Plan)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+)
+
+func lobbySpec(replicas int) Spec {
+	return Spec{
+		Name:     "lobby",
+		Replicas: replicas,
+		Request: orchestrator.AllocateRequest{
+			Image:       "hytale-lobby:1",
+			MemoryLimit: 1 << 30,
+			Labels:      map[string]string{"type": "lobby"},
+		},
+	}
+}
+
+func names(t *testing.T, p orchestrator.Provider) []string {
+	t.Helper()
+	servers, err := p.List(context.Background(), map[string]string{LabelSpec: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, s := range sortByName(servers) {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestReconcileScales(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	stray, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "by-hand"})
+
+	r, err := New(p, []Spec{lobbySpec(3)}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names(t, p), " "); got != "lobby-1 lobby-2 lobby-3" {
+		t.Errorf("servers = %s", got)
+	}
+	server, _ := p.List(ctx, map[string]string{LabelSpec: "lobby", "type": "lobby"})
+	if len(server) != 3 || server[0].Labels[LabelSpecHash] != Hash(lobbySpec(0).Request) {
+		t.Errorf("labels = %v", server[0].Labels)
+	}
+
+	// Converged: nothing to do
+	if result, _ := r.Reconcile(ctx); !result.Plan.Empty() {
+		t.Errorf("second pass planned:\n%s", result.Plan)
+	}
+
+	// A gap is refilled with the lowest free name
+	p.Deallocate(ctx, server[1].ID)
+	r.SetSpecs([]Spec{lobbySpec(4)})
+	r.Reconcile(ctx)
+	if got := strings.Join(names(t, p), " "); got != "lobby-1 lobby-2 lobby-3 lobby-4" {
+		t.Errorf("after scale up = %s", got)
+	}
+
+	r.SetSpecs([]Spec{lobbySpec(2)})
+	r.Reconcile(ctx)
+	if got := strings.Join(names(t, p), " "); got != "lobby-1 lobby-2" {
+		t.Errorf("after scale down = %s", got)
+	}
+
+	r.SetSpecs(nil)
+	r.Reconcile(ctx)
+	if got := names(t, p); len(got) != 0 {
+		t.Errorf("undeclared spec left %v", got)
+	}
+	if _, err := p.Get(ctx, stray.ID); err != nil {
+		t.Errorf("unlabelled server was touched: %v", err)
+	}
+}
+
+func TestReconcileDrift(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	r, _ := New(p, []Spec{lobbySpec(2)}, Config{})
+	r.Reconcile(ctx)
+	before, _ := p.List(ctx, map[string]string{LabelSpec: "lobby"})
+
+	// Limit drift on one replica recreates only that one
+	p.UpdateResources(ctx, before[0].ID, orchestrator.ResourceUpdate{MemoryLimit: 2 << 30})
+	plan, _ := r.Plan(ctx)
+	if len(plan.Actions) != 1 || plan.Actions[0].Type != ActionRecreate || !strings.Contains(plan.Actions[0].Reason, "memory limit") {
+		t.Fatalf("plan:\n%s", plan)
+	}
+	r.Reconcile(ctx)
+	if _, err := p.Get(ctx, before[0].ID); !errors.Is(err, orchestrator.ErrNotFound) {
+		t.Error("drifted server not removed")
+	}
+	if _, err := p.Get(ctx, before[1].ID); err != nil {
+		t.Error("matching server was recreated")
+	}
+	if got := strings.Join(names(t, p), " "); got != "lobby-1 lobby-2" {
+		t.Errorf("names after recreate = %s", got)
+	}
+
+	// A spec change rolls through one replica per pass
+	spec := lobbySpec(2)
+	spec.Request.Image = "hytale-lobby:2"
+	r.SetSpecs([]Spec{spec})
+	result, err := r.Reconcile(ctx)
+	if err != nil || len(result.Applied) != 1 || len(result.Deferred) != 1 {
+		t.Fatalf("applied %d, deferred %d, %v", len(result.Applied), len(result.Deferred), err)
+	}
+	r.Reconcile(ctx)
+	after, _ := p.List(ctx, map[string]string{LabelSpec: "lobby"})
+	for _, s := range after {
+		if req, _ := p.Request(s.ID); req.Image != "hytale-lobby:2" {
+			t.Errorf("%s still runs %s", s.Name, req.Image)
+		}
+	}
+}
+
+func TestDryRunAndRateLimit(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+
+	r, _ := New(p, []Spec{lobbySpec(3)}, Config{DryRun: true})
+	result, err := r.Reconcile(ctx)
+	if err != nil || len(result.Plan.Actions) != 3 || len(result.Applied) != 0 {
+		t.Fatalf("dry run = %+v, %v", result, err)
+	}
+	if !strings.HasPrefix(result.Plan.String(), "+ create lobby/lobby-1: scale up to 3") {
+		t.Errorf("plan output:\n%s", result.Plan)
+	}
+	if p.Calls(orchestratortest.OpAllocate) != 0 {
+		t.Error("dry run allocated")
+	}
+
+	r, _ = New(p, []Spec{lobbySpec(3)}, Config{MaxActions: 2})
+	result, _ = r.Reconcile(ctx)
+	if len(result.Applied) != 2 || len(result.Deferred) != 1 {
+		t.Errorf("applied %d, deferred %d", len(result.Applied), len(result.Deferred))
+	}
+}
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	r, _ := New(p, []Spec{lobbySpec(2)}, Config{})
+	r.Reconcile(ctx)
+
+	draining := true
+	var after []string
+	r.cfg.Hooks = Hooks{
+		BeforeAction: func(ctx context.Context, a Action) error {
+			if a.Type == ActionRemove && draining {
+				return ErrDefer
+			}
+			return nil
+		},
+		AfterAction: func(ctx context.Context, a Action, s *orchestrator.Server, err error) {
+			after = append(after, string(a.Type)+" "+a.Name)
+		},
+	}
+	r.SetSpecs([]Spec{lobbySpec(1)})
+
+	result, err := r.Reconcile(ctx)
+	if err != nil || len(result.Deferred) != 1 || len(after) != 0 {
+		t.Fatalf("deferred %d, after %v, %v", len(result.Deferred), after, err)
+	}
+	draining = false
+	r.Reconcile(ctx)
+	if len(after) != 1 || after[0] != "remove lobby-2" {
+		t.Errorf("after hooks = %v", after)
+	}
+
+	p.FailNext(orchestratortest.OpAllocate, orchestrator.ErrResourceExhausted)
+	r.SetSpecs([]Spec{lobbySpec(2)})
+	if _, err := r.Reconcile(ctx); !errors.Is(err, orchestrator.ErrResourceExhausted) {
+		t.Errorf("failed create = %v", err)
+	}
+}
+
+func TestSetSpecsValidates(t *testing.T) {
+	p := orchestratortest.New()
+	bad := lobbySpec(1)
+	bad.Request.RestartPolicy = "sometimes"
+	if _, err := New(p, []Spec{lobbySpec(1), lobbySpec(2)}, Config{}); err == nil {
+		t.Error("accepted duplicate spec names")
+	}
+	if _, err := New(p, []Spec{bad}, Config{}); err == nil {
+		t.Error("accepted an invalid request")
+	}
+	if _, err := New(p, []Spec{lobbySpec(1)}, Config{Selector: map[string]string{"type": "game"}}); err == nil {
+		t.Error("accepted a spec outside the selector")
+	}
+}
@@ -0,0 +1,25 @@
+package orchestrator
+
+import "context"
+
+// ContainerStats represents a point-in-time resource usage snapshot.
+// Network and disk counters are cumulative since the server started.
+type ContainerStats struct {
+	ContainerID    string  `json:"container_id"`
+	Name           string  `json:"name"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MemoryUsed     int64   `json:"memory_used"`
+	MemoryLimit    int64   `json:"memory_limit"`
+	NetRxBytes     int64   `json:"net_rx_bytes"`
+	NetTxBytes     int64   `json:"net_tx_bytes"`
+	DiskReadBytes  int64   `json:"disk_read_bytes"`
+	DiskWriteBytes int64   `json:"disk_write_bytes"`
+	Timestamp      int64   `json:"timestamp"`
+}
+
+// StatsSource is implemented by providers that report resource usage.
+type StatsSource interface {
+	Stats(ctx context.Context, id string) (*ContainerStats, error)
+	// StatsAll returns a snapshot for every running server.
+	StatsAll(ctx context.Context) ([]ContainerStats, error)
+}
@@ -0,0 +1,26 @@
+package orchestrator
+
+// StopOptions controls how DeallocateWithOptions and RestartWithOptions
+// stop a server. The zero value behaves like Deallocate/Restart: the
+// runtime's default stop signal and grace period.
+type StopOptions struct {
+	// Signal sent to the server process, e.g. "SIGINT". Empty uses the
+	// runtime default (SIGTERM, or the image's STOPSIGNAL).
+	Signal string `json:"signal,omitempty" yaml:"signal,omitempty"`
+	// GracePeriodSeconds is how long the process has to exit after the
+	// signal before it is killed. 0 uses the runtime default.
+	GracePeriodSeconds int `json:"grace_period_seconds,omitempty" yaml:"grace_period_seconds,omitempty"`
+	// PreStopCommand is written to the server console before any signal,
+	// e.g. "save-all" or "stop", so the world is saved cleanly.
+	PreStopCommand string `json:"pre_stop_command,omitempty" yaml:"pre_stop_command,omitempty"`
+	// PreStopTimeoutSeconds is how long to wait for the server to exit on
+	// its own after PreStopCommand before signalling it. Default 30.
+	PreStopTimeoutSeconds int `json:"pre_stop_timeout_seconds,omitempty" yaml:"pre_stop_timeout_seconds,omitempty"`
+	// RemoveVolumes also removes the server's anonymous volumes.
+	// Deallocate only.
+	RemoveVolumes bool `json:"remove_volumes,omitempty" yaml:"remove_volumes,omitempty"`
+}
+
+// DefaultPreStopTimeoutSeconds applies when PreStopCommand is set
+// without PreStopTimeoutSeconds.
+const DefaultPreStopTimeoutSeconds = 30
@@ -0,0 +1,439 @@
+// Package supervisor restarts servers that exit on their own, backing
+// off exponentially and giving up on servers that keep crashing.
+//
+//	s, err := supervisor.New(provider, supervisor.Config{
+//		Selector:    map[string]string{"type": "game"},
+//		OnCrashLoop: func(h supervisor.History) { alert(h) },
+//	})
+//	go s.Run(ctx)
+//
+//	h, _ := s.History(serverID)
+//	fmt.Println(h.State, h.Crashes[len(h.Crashes)-1].Logs)
+//
+// Crashes are detected from the provider's die events. A die followed
+// by stop or destroy within Config.Settle is an intentional stop
+// (Deallocate, Restart) and is ignored. Servers the runtime restarts
+// itself, through their restart policy, are recorded but not restarted
+// again. The supervisor never stops a server: one marked crash-looping
+// stays down only if the runtime doesn't restart it.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+)
+
+// State is where a supervised server stands.
+type State string
+
+const (
+	StateRunning      State = "running"
+	StateBackoff      State = "backoff"       // crashed, restart scheduled
+	StateCrashLooping State = "crash-looping" // given up until Reset
+)
+
+// Crash is one unexpected exit.
+type Crash struct {
+	Time      time.Time `json:"time"`
+	ExitCode  int       `json:"exit_code"`
+	OOMKilled bool      `json:"oom_killed,omitempty"`
+	// Logs is the tail of the server's output at the time it exited.
+	Logs string `json:"logs,omitempty"`
+	// Backoff is the delay before the supervisor restarted the server.
+	// Zero when it wasn't restarted by the supervisor.
+	Backoff      time.Duration `json:"backoff,omitempty"`
+	RestartedAt  time.Time     `json:"restarted_at,omitzero"`
+	RestartError string        `json:"restart_error,omitempty"`
+}
+
+// History is the crash record of one server.
+type History struct {
+	ServerID string `json:"server_id"`
+	Name     string `json:"name"`
+	State    State  `json:"state"`
+	// Crashes are oldest first, up to Config.HistorySize.
+	Crashes []Crash `json:"crashes"`
+	// Restarts counts restarts performed by the supervisor.
+	Restarts    int       `json:"restarts"`
+	NextRestart time.Time `json:"next_restart,omitzero"`
+}
+
+// Config controls a Supervisor.
+type Config struct {
+	// Selector limits supervision to servers whose labels match, using
+	// Provider.List semantics. Nil supervises every server.
+	Selector map[string]string
+	// InitialBackoff is the delay before the first restart; it doubles
+	// with each crash inside CrashLoopWindow up to MaxBackoff. Defaults
+	// 1 second and 5 minutes.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// CrashLoopThreshold crashes within CrashLoopWindow mark a server
+	// crash-looping; the supervisor stops restarting it and ignores its
+	// further crashes until Reset. A runtime restart policy still
+	// applies. Defaults 5 and 10 minutes.
+	CrashLoopThreshold int
+	CrashLoopWindow    time.Duration
+	// Settle is how long after a die to wait for a stop or destroy
+	// showing the exit was intentional. Default 2 seconds.
+	Settle time.Duration
+	// LogTail is how many log lines a crash report keeps. Default 100.
+	LogTail int
+	// HistorySize is how many crashes are kept per server. Default 20.
+	HistorySize int
+	// OnCrash is called after every recorded crash, OnCrashLoop when a
+	// server is given up on.
+	OnCrash     func(History)
+	OnCrashLoop func(History)
+}
+
+const (
+	defaultInitialBackoff     = time.Second
+	defaultMaxBackoff         = 5 * time.Minute
+	defaultCrashLoopThreshold = 5
+	defaultCrashLoopWindow    = 10 * time.Minute
+	defaultSettle             = 2 * time.Second
+	defaultLogTail            = 100
+	defaultHistorySize        = 20
+)
+
+type tracked struct {
+	history History
+	// gen invalidates the pending timer when it changes
+	gen   int
+	timer *time.Timer
+}
+
+func (t *tracked) cancel() {
+	t.gen++
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// Supervisor watches a provider's events and restarts crashed servers.
+type Supervisor struct {
+	provider orchestrator.Provider
+	events   orchestrator.EventSource
+	cfg      Config
+	now      func() time.Time
+
+	mu      sync.Mutex
+	ctx     context.Context
+	servers map[string]*tracked
+}
+
+// New returns a supervisor for provider, which must be an
+// orchestrator.EventSource.
+func New(provider orchestrator.Provider, cfg Config) (*Supervisor, error) {
+	events, ok := provider.(orchestrator.EventSource)
+	if !ok {
+		return nil, errors.New("supervisor needs a provider that streams events")
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.CrashLoopThreshold <= 0 {
+		cfg.CrashLoopThreshold = defaultCrashLoopThreshold
+	}
+	if cfg.CrashLoopWindow <= 0 {
+		cfg.CrashLoopWindow = defaultCrashLoopWindow
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = defaultSettle
+	}
+	if cfg.LogTail <= 0 {
+		cfg.LogTail = defaultLogTail
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = defaultHistorySize
+	}
+	return &Supervisor{
+		provider: provider,
+		events:   events,
+		cfg:      cfg,
+		now:      time.Now,
+		servers:  make(map[string]*tracked),
+	}, nil
+}
+
+// Run supervises until ctx is cancelled or the event stream ends.
+// Stream errors are logged; pending restarts are cancelled on return.
+func (s *Supervisor) Run(ctx context.Context) error {
+	events, errs := s.events.Events(ctx)
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		for _, t := range s.servers {
+			t.cancel()
+		}
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return errors.New("supervisor: event stream closed")
+			}
+			s.handle(e)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("supervisor: %v", err)
+		}
+	}
+}
+
+func (s *Supervisor) handle(e orchestrator.ContainerEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.servers[e.ContainerID]
+	switch e.Action {
+	case orchestrator.EventDie:
+		if t == nil {
+			t = &tracked{history: History{ServerID: e.ContainerID, State: StateRunning}}
+			s.servers[e.ContainerID] = t
+		}
+		if t.history.State == StateCrashLooping {
+			return
+		}
+		t.cancel()
+		gen := t.gen
+		t.timer = time.AfterFunc(s.cfg.Settle, func() { s.crashed(e, gen) })
+	case orchestrator.EventStop:
+		// The die was a requested stop
+		if t != nil && t.history.State == StateRunning {
+			t.cancel()
+			if len(t.history.Crashes) == 0 {
+				delete(s.servers, e.ContainerID)
+			}
+		}
+	case orchestrator.EventStart:
+		// Started by someone else before our restart came due
+		if t != nil && t.history.State == StateBackoff {
+			t.cancel()
+			t.history.State = StateRunning
+			t.history.NextRestart = time.Time{}
+		}
+	case orchestrator.EventDestroy:
+		if t != nil {
+			t.cancel()
+			delete(s.servers, e.ContainerID)
+		}
+	}
+}
+
+// crashed runs Settle after a die that wasn't explained by a stop.
+func (s *Supervisor) crashed(e orchestrator.ContainerEvent, gen int) {
+	ctx := s.runContext()
+	if ctx == nil || ctx.Err() != nil {
+		return
+	}
+
+	id := e.ContainerID
+	server, err := s.provider.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, orchestrator.ErrNotFound) {
+			s.forget(id, gen)
+		} else {
+			log.Printf("supervisor: %s: %v", id, err)
+		}
+		return
+	}
+	if !orchestrator.MatchLabels(server.Labels, s.cfg.Selector) {
+		s.forget(id, gen)
+		return
+	}
+	logs, err := s.provider.Logs(ctx, id, s.cfg.LogTail)
+	if err != nil {
+		logs = fmt.Sprintf("logs unavailable: %v", err)
+	}
+
+	s.mu.Lock()
+	t, ok := s.servers[id]
+	if !ok || t.gen != gen {
+		s.mu.Unlock()
+		return
+	}
+	t.timer = nil
+	now := s.now()
+	h := &t.history
+	h.Name = server.Name
+	h.Crashes = append(h.Crashes, Crash{
+		Time:      now,
+		ExitCode:  e.ExitCode,
+		OOMKilled: e.OOMKilled,
+		Logs:      logs,
+	})
+	if len(h.Crashes) > s.cfg.HistorySize {
+		h.Crashes = h.Crashes[len(h.Crashes)-s.cfg.HistorySize:]
+	}
+
+	recent := 0
+	for _, c := range h.Crashes {
+		if now.Sub(c.Time) < s.cfg.CrashLoopWindow {
+			recent++
+		}
+	}
+	switch {
+	case recent >= s.cfg.CrashLoopThreshold:
+		h.State = StateCrashLooping
+	case server.Status == orchestrator.StatusRunning || server.Status == orchestrator.StatusPaused:
+		// The runtime's restart policy already brought it back
+		h.State = StateRunning
+	default:
+		delay := s.backoff(recent)
+		h.State = StateBackoff
+		h.NextRestart = now.Add(delay)
+		h.Crashes[len(h.Crashes)-1].Backoff = delay
+		gen := t.gen
+		t.timer = time.AfterFunc(delay, func() { s.restart(id, gen, delay) })
+	}
+	snapshot := copyHistory(*h)
+	s.mu.Unlock()
+
+	if s.cfg.OnCrash != nil {
+		s.cfg.OnCrash(snapshot)
+	}
+	if snapshot.State == StateCrashLooping && s.cfg.OnCrashLoop != nil {
+		s.cfg.OnCrashLoop(snapshot)
+	}
+}
+
+// backoff doubles InitialBackoff for each recent crash after the first.
+func (s *Supervisor) backoff(recent int) time.Duration {
+	delay := s.cfg.InitialBackoff
+	for i := 1; i < recent && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxBackoff)
+}
+
+func (s *Supervisor) restart(id string, gen int, delay time.Duration) {
+	ctx := s.runContext()
+	if ctx == nil || ctx.Err() != nil {
+		return
+	}
+	s.mu.Lock()
+	if t, ok := s.servers[id]; !ok || t.gen != gen {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	err := s.provider.Restart(ctx, id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.servers[id]
+	if !ok || t.gen != gen {
+		return
+	}
+	if errors.Is(err, orchestrator.ErrNotFound) {
+		t.cancel()
+		delete(s.servers, id)
+		return
+	}
+	h := &t.history
+	last := &h.Crashes[len(h.Crashes)-1]
+	if err != nil {
+		// Try again later, without counting another crash
+		last.RestartError = err.Error()
+		delay = min(delay*2, s.cfg.MaxBackoff)
+		h.NextRestart = s.now().Add(delay)
+		t.timer = time.AfterFunc(delay, func() { s.restart(id, gen, delay) })
+		return
+	}
+	last.RestartedAt = s.now()
+	last.RestartError = ""
+	h.State = StateRunning
+	h.NextRestart = time.Time{}
+	h.Restarts++
+	t.timer = nil
+}
+
+func (s *Supervisor) forget(id string, gen int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.servers[id]; ok && t.gen == gen && len(t.history.Crashes) == 0 {
+		delete(s.servers, id)
+	}
+}
+
+func (s *Supervisor) runContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+// History returns the crash record of a server. ok is false if it
+// hasn't crashed since the supervisor started.
+func (s *Supervisor) History(id string) (History, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.servers[id]
+	if !ok || len(t.history.Crashes) == 0 {
+		return History{}, false
+	}
+	return copyHistory(t.history), true
+}
+
+// Histories returns the records of every server that has crashed,
+// sorted by ID.
+func (s *Supervisor) Histories() []History {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all []History
+	for _, t := range s.servers {
+		if len(t.history.Crashes) > 0 {
+			all = append(all, copyHistory(t.history))
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ServerID < all[j].ServerID })
+	return all
+}
+
+// Reset clears a server's crash history, ending a crash loop or pending
+// backoff, and starts it if it isn't running.
+func (s *Supervisor) Reset(ctx context.Context, id string) error {
+	s.mu.Lock()
+	if t, ok := s.servers[id]; ok {
+		t.cancel()
+		delete(s.servers, id)
+	}
+	s.mu.Unlock()
+
+	server, err := s.provider.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if server.Status == orchestrator.StatusRunning || server.Status == orchestrator.StatusPaused {
+		return nil
+	}
+	return s.provider.Restart(ctx, id)
+}
+
+func copyHistory(h History) History {
+	h.Crashes = append([]Crash(nil), h.Crashes...)
+	return h
+}
@@ -0,0 +1,234 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/potassium/orchestrator"
+	"github.com/bananalabs-oss/potassium/orchestrator/orchestratortest"
+)
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// restartPolicy reports every server running, as the runtime has
+// already restarted one with a restart policy by the time the
+// supervisor looks.
+type restartPolicy struct {
+	*orchestratortest.Provider
+}
+
+func (r restartPolicy) Get(ctx context.Context, id string) (*orchestrator.Server, error) {
+	s, err := r.Provider.Get(ctx, id)
+	if err == nil {
+		s.Status = orchestrator.StatusRunning
+	}
+	return s, err
+}
+
+func newTestSupervisor(t *testing.T, p orchestrator.Provider, cfg Config) *Supervisor {
+	t.Helper()
+	if cfg.Settle == 0 {
+		cfg.Settle = 20 * time.Millisecond
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = 10 * time.Millisecond
+	}
+	s, err := New(p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	// Let Run subscribe before the test emits events
+	waitFor(t, "subscription", func() bool { return s.runContext() != nil })
+	return s
+}
+
+func status(p *orchestratortest.Provider, id string) orchestrator.ServerStatus {
+	s, err := p.Get(context.Background(), id)
+	if err != nil {
+		return ""
+	}
+	return s.Status
+}
+
+func TestRestartsCrashedServer(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Name: "game-1"})
+	s := newTestSupervisor(t, p, Config{})
+
+	p.AppendLogs(server.ID, "loading world", "java.lang.OutOfMemoryError")
+	p.Exit(server.ID, 137, true)
+
+	waitFor(t, "restart", func() bool {
+		h, ok := s.History(server.ID)
+		return ok && h.State == StateRunning && h.Restarts == 1
+	})
+	if status(p, server.ID) != orchestrator.StatusRunning {
+		t.Error("server not running after restart")
+	}
+	h, _ := s.History(server.ID)
+	crash := h.Crashes[0]
+	if crash.ExitCode != 137 || !crash.OOMKilled || crash.RestartedAt.IsZero() {
+		t.Errorf("crash = %+v", crash)
+	}
+	if !strings.Contains(crash.Logs, "OutOfMemoryError") {
+		t.Errorf("crash report logs = %q", crash.Logs)
+	}
+	if h.Name != "game-1" {
+		t.Errorf("name = %q", h.Name)
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+
+	var mu sync.Mutex
+	var looped []History
+	s := newTestSupervisor(t, p, Config{
+		CrashLoopThreshold: 3,
+		OnCrashLoop: func(h History) {
+			mu.Lock()
+			looped = append(looped, h)
+			mu.Unlock()
+		},
+	})
+
+	for i := range 3 {
+		p.Crash(server.ID)
+		if i < 2 {
+			waitFor(t, "restart", func() bool {
+				h, _ := s.History(server.ID)
+				return h.Restarts == i+1
+			})
+		}
+	}
+	waitFor(t, "crash loop", func() bool {
+		h, _ := s.History(server.ID)
+		return h.State == StateCrashLooping
+	})
+
+	h, _ := s.History(server.ID)
+	if len(h.Crashes) != 3 || h.Crashes[1].Backoff != 2*h.Crashes[0].Backoff {
+		t.Errorf("backoffs = %v, %v", h.Crashes[0].Backoff, h.Crashes[1].Backoff)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status(p, server.ID) != orchestrator.StatusStopped {
+		t.Error("crash-looping server was restarted")
+	}
+	mu.Lock()
+	if len(looped) != 1 {
+		t.Errorf("OnCrashLoop called %d times", len(looped))
+	}
+	mu.Unlock()
+
+	if err := s.Reset(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.History(server.ID); ok {
+		t.Error("history kept after Reset")
+	}
+	if status(p, server.ID) != orchestrator.StatusRunning {
+		t.Error("Reset didn't start the server")
+	}
+}
+
+func TestIgnoresIntentionalStops(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	game := map[string]string{"type": "game"}
+	restarted, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Labels: game})
+	removed, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Labels: game})
+	unselected, _ := p.Allocate(ctx, orchestrator.AllocateRequest{Labels: map[string]string{"type": "lobby"}})
+	s := newTestSupervisor(t, p, Config{Selector: game})
+
+	p.Restart(ctx, restarted.ID)
+	p.Deallocate(ctx, removed.ID)
+	p.Crash(unselected.ID)
+	time.Sleep(100 * time.Millisecond)
+
+	if all := s.Histories(); len(all) != 0 {
+		t.Errorf("histories = %+v", all)
+	}
+	if p.Calls(orchestratortest.OpRestart) != 1 {
+		t.Errorf("restarts = %d, want only the test's own", p.Calls(orchestratortest.OpRestart))
+	}
+}
+
+func TestCrashLoopWithRestartPolicy(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{RestartPolicy: orchestrator.RestartAlways})
+	s := newTestSupervisor(t, restartPolicy{p}, Config{CrashLoopThreshold: 2})
+
+	for i := range 2 {
+		p.Crash(server.ID)
+		waitFor(t, "crash", func() bool {
+			h, _ := s.History(server.ID)
+			return len(h.Crashes) == i+1
+		})
+	}
+	waitFor(t, "crash loop", func() bool {
+		h, _ := s.History(server.ID)
+		return h.State == StateCrashLooping
+	})
+
+	// Marked, but left to the runtime: neither restarted nor stopped
+	time.Sleep(50 * time.Millisecond)
+	if n := p.Calls(orchestratortest.OpRestart) + p.Calls(orchestratortest.OpDeallocate); n != 0 {
+		t.Errorf("supervisor restarted or stopped the server %d times", n)
+	}
+	h, _ := s.History(server.ID)
+	if h.Restarts != 0 {
+		t.Errorf("Restarts = %d, want 0", h.Restarts)
+	}
+}
+
+func TestRestartFailureRetries(t *testing.T) {
+	ctx := context.Background()
+	p := orchestratortest.New()
+	server, _ := p.Allocate(ctx, orchestrator.AllocateRequest{})
+	s := newTestSupervisor(t, p, Config{})
+
+	p.FailNext(orchestratortest.OpRestart, errors.New("daemon busy"))
+	p.Crash(server.ID)
+	waitFor(t, "retried restart", func() bool {
+		h, _ := s.History(server.ID)
+		return h.Restarts == 1
+	})
+	if h, _ := s.History(server.ID); len(h.Crashes) != 1 {
+		t.Errorf("failed restart counted as a crash: %d crashes", len(h.Crashes))
+	}
+}
+
+func TestNeedsEventSource(t *testing.T) {
+	var p struct{ orchestrator.Provider }
+	if _, err := New(p, Config{}); err == nil {
+		t.Error("accepted a provider without events")
+	}
+}
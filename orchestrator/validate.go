@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// Restart policies accepted in AllocateRequest.RestartPolicy.
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+	RestartOnFailure     = "on-failure"
+)
+
+// namespacedSysctls are the sysctl prefixes that can be set per
+// container; anything else would change the host kernel.
+var namespacedSysctls = []string{"net.", "kernel.shm", "kernel.msg", "kernel.sem", "fs.mqueue."}
+
+// Validate rejects requests that can't be honored, so a bad spec fails
+// before any container is created rather than halfway through.
+func (r AllocateRequest) Validate() error {
+	var errs []error
+
+	switch r.RestartPolicy {
+	case "", RestartNo, RestartAlways, RestartUnlessStopped, RestartOnFailure:
+	default:
+		errs = append(errs, fmt.Errorf("unknown restart policy %q", r.RestartPolicy))
+	}
+	if r.RestartMaxRetries < 0 {
+		errs = append(errs, errors.New("restart_max_retries must not be negative"))
+	}
+	if r.RestartMaxRetries > 0 && r.RestartPolicy != RestartOnFailure {
+		errs = append(errs, errors.New("restart_max_retries requires restart_policy on-failure"))
+	}
+
+	if r.IP != "" && r.Network == "" {
+		errs = append(errs, errors.New("ip requires network"))
+	}
+	for _, p := range r.Ports {
+		if p.Range == "" {
+			continue
+		}
+		if r.Network != "" {
+			errs = append(errs, fmt.Errorf("port %d: range is not used on overlay network %q", p.Container, r.Network))
+		} else if _, _, err := ParsePortRange(p.Range); err != nil {
+			errs = append(errs, fmt.Errorf("port %d: %w", p.Container, err))
+		}
+	}
+
+	seenUlimits := map[string]bool{}
+	for _, u := range r.Ulimits {
+		switch {
+		case u.Name == "":
+			errs = append(errs, errors.New("ulimit name required"))
+		case seenUlimits[u.Name]:
+			errs = append(errs, fmt.Errorf("ulimit %s set twice", u.Name))
+		case u.Hard >= 0 && (u.Soft < 0 || u.Soft > u.Hard):
+			errs = append(errs, fmt.Errorf("ulimit %s: soft %d exceeds hard %d", u.Name, u.Soft, u.Hard))
+		}
+		seenUlimits[u.Name] = true
+	}
+
+	for key := range r.Sysctls {
+		if !isNamespacedSysctl(key) {
+			errs = append(errs, fmt.Errorf("sysctl %s is not namespaced and can't be set per server", key))
+		}
+	}
+
+	volumeTargets := map[string]bool{}
+	for _, target := range r.Volumes {
+		volumeTargets[path.Clean(target)] = true
+	}
+	for target := range r.Tmpfs {
+		if !path.IsAbs(target) {
+			errs = append(errs, fmt.Errorf("tmpfs path must be absolute, got %q", target))
+		} else if volumeTargets[path.Clean(target)] {
+			errs = append(errs, fmt.Errorf("tmpfs %s conflicts with a volume mounted there", target))
+		}
+	}
+
+	if r.ReadOnlyRootfs && r.DiskSizeLimit > 0 {
+		errs = append(errs, errors.New("disk_size_limit has no effect with read_only_rootfs"))
+	}
+
+	for _, host := range r.ExtraHosts {
+		name, ip, ok := strings.Cut(host, ":")
+		if !ok || name == "" || (ip != "host-gateway" && net.ParseIP(ip) == nil) {
+			errs = append(errs, fmt.Errorf("extra host %q must be host:ip", host))
+		}
+	}
+	for _, server := range r.DNS {
+		if net.ParseIP(server) == nil {
+			errs = append(errs, fmt.Errorf("dns server %q is not an IP address", server))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isNamespacedSysctl(key string) bool {
+	for _, prefix := range namespacedSysctls {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		req     AllocateRequest
+		wantErr string
+	}{
+		{"empty", AllocateRequest{}, ""},
+		{"full spec", AllocateRequest{
+			Image:             "localhost/hytale-server",
+			RestartPolicy:     RestartOnFailure,
+			RestartMaxRetries: 3,
+			Ulimits:           []Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+			Sysctls:           map[string]string{"net.core.somaxconn": "1024"},
+			Tmpfs:             map[string]string{"/tmp": "size=64m"},
+			ReadOnlyRootfs:    true,
+			DNS:               []string{"1.1.1.1", "2606:4700:4700::1111"},
+			ExtraHosts:        []string{"db:10.0.0.2", "host.docker.internal:host-gateway"},
+		}, ""},
+		{"unknown restart policy", AllocateRequest{RestartPolicy: "sometimes"}, "unknown restart policy"},
+		{"retries without on-failure", AllocateRequest{RestartPolicy: RestartAlways, RestartMaxRetries: 2}, "requires restart_policy on-failure"},
+		{"ip without network", AllocateRequest{IP: "10.99.0.10"}, "ip requires network"},
+		{"range on overlay", AllocateRequest{
+			Network: "banananet",
+			Ports:   []PortBinding{{Container: 5520, Range: "25565-25570"}},
+		}, "range is not used"},
+		{"bad range", AllocateRequest{Ports: []PortBinding{{Container: 5520, Range: "9-1"}}}, "port 5520"},
+		{"duplicate ulimit", AllocateRequest{Ulimits: []Ulimit{{Name: "nofile"}, {Name: "nofile"}}}, "set twice"},
+		{"soft above hard", AllocateRequest{Ulimits: []Ulimit{{Name: "nproc", Soft: 10, Hard: 5}}}, "exceeds hard"},
+		{"unlimited hard", AllocateRequest{Ulimits: []Ulimit{{Name: "nofile", Soft: 65536, Hard: -1}}}, ""},
+		{"both unlimited", AllocateRequest{Ulimits: []Ulimit{{Name: "memlock", Soft: -1, Hard: -1}}}, ""},
+		{"unlimited soft", AllocateRequest{Ulimits: []Ulimit{{Name: "memlock", Soft: -1, Hard: 4096}}}, "exceeds hard"},
+		{"host sysctl", AllocateRequest{Sysctls: map[string]string{"vm.swappiness": "10"}}, "not namespaced"},
+		{"relative tmpfs", AllocateRequest{Tmpfs: map[string]string{"tmp": ""}}, "must be absolute"},
+		{"tmpfs over volume", AllocateRequest{
+			Volumes: map[string]string{"/srv/world": "/data"},
+			Tmpfs:   map[string]string{"/data/": ""},
+		}, "conflicts with a volume"},
+		{"read-only with disk size", AllocateRequest{ReadOnlyRootfs: true, DiskSizeLimit: 1 << 30}, "read_only_rootfs"},
+		{"bad extra host", AllocateRequest{ExtraHosts: []string{"db"}}, "must be host:ip"},
+		{"hostname dns", AllocateRequest{DNS: []string{"dns.google"}}, "not an IP address"},
+	}
+	for _, c := range cases {
+		err := c.req.Validate()
+		if c.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: error = %v, want containing %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	err := AllocateRequest{
+		RestartPolicy: "sometimes",
+		IP:            "10.99.0.10",
+		DNS:           []string{"nope"},
+	}.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := len(strings.Split(err.Error(), "\n")); n != 3 {
+		t.Errorf("got %d problems, want 3: %v", n, err)
+	}
+}